@@ -1,7 +1,10 @@
 package v1alpha1
 
 import (
+	"strings"
+
 	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -14,6 +17,55 @@ const (
 	// ReconciliationSucceededReason represents the fact that
 	// the reconciliation succeeded.
 	ReconciliationSucceededReason string = "ReconciliationSucceeded"
+
+	// DeploymentGenerationFailedReason represents the fact that the shard
+	// Deployments can't be generated from the source Deployment, this
+	// won't be resolved until the FluxShardSet or source Deployment changes.
+	DeploymentGenerationFailedReason string = "DeploymentGenerationFailed"
+
+	// SourceDeploymentNotFoundReason represents the fact that the source
+	// Deployment referenced by the FluxShardSet doesn't exist.
+	SourceDeploymentNotFoundReason string = "SourceDeploymentNotFound"
+
+	// OrphanedObjectsCondition indicates that there are Flux objects labelled
+	// for shards that are not served.
+	OrphanedObjectsCondition string = "OrphanedObjects"
+
+	// OrphanedObjectsFoundReason represents the fact that Flux objects were
+	// found with shard keys that are not served.
+	OrphanedObjectsFoundReason string = "OrphanedObjectsFound"
+
+	// NoOrphanedObjectsReason represents the fact that all the Flux objects
+	// with shard keys are served.
+	NoOrphanedObjectsReason string = "NoOrphanedObjects"
+
+	// DriftedCondition indicates that shard Deployments have been changed and
+	// no longer match the generated Deployments.
+	DriftedCondition string = "Drifted"
+
+	// DriftDetectedReason represents the fact that shard Deployments have
+	// drifted and the drift was not corrected.
+	DriftDetectedReason string = "DriftDetected"
+
+	// NoDriftReason represents the fact that the shard Deployments match the
+	// generated Deployments.
+	NoDriftReason string = "NoDrift"
+
+	// RolloutPausedCondition indicates that the rollout of changes to the
+	// shard Deployments is paused because a shard failed to roll out.
+	RolloutPausedCondition string = "RolloutPaused"
+
+	// RolloutFailedReason represents the fact that a shard Deployment or the
+	// Flux objects assigned to the shard failed after the shard was updated.
+	RolloutFailedReason string = "RolloutFailed"
+
+	// NoRolloutFailuresReason represents the fact that no shard has failed to
+	// roll out.
+	NoRolloutFailuresReason string = "NoRolloutFailures"
+
+	// MaxOrphanedObjects is the maximum number of orphaned objects that are
+	// recorded in the status.
+	MaxOrphanedObjects = 20
 )
 
 // SetFluxShardSetReadiness sets the ready condition with the given status, reason and message.
@@ -25,22 +77,95 @@ func SetFluxShardSetReadiness(set *FluxShardSet, status metav1.ConditionStatus,
 		Reason:  reason,
 		Message: message,
 	}
-	apimeta.SetStatusCondition(&set.Status.Conditions, newCondition)
+	conditions.Set(set, &newCondition)
 }
 
 // SetReadyWithInventory updates the FluxShardSet to reflect the new readiness and
 // store the current inventory.
+//
+// The FluxShardSet is no longer Reconciling or Stalled.
 func SetReadyWithInventory(set *FluxShardSet, inventory *ResourceInventory, reason, message string) {
+	setInventory(set, inventory)
+
+	conditions.Delete(set, meta.ReconcilingCondition)
+	conditions.Delete(set, meta.StalledCondition)
+	SetFluxShardSetReadiness(set, metav1.ConditionTrue, reason, message)
+}
+
+// SetProgressingWithInventory updates the FluxShardSet to reflect that the
+// Deployments have been created but are not all available, and stores the
+// current inventory.
+//
+// The FluxShardSet is Reconciling until the Deployments are available.
+func SetProgressingWithInventory(set *FluxShardSet, inventory *ResourceInventory, message string) {
+	setInventory(set, inventory)
+
+	conditions.MarkReconciling(set, meta.ProgressingReason, "%s", message)
+	SetFluxShardSetReadiness(set, metav1.ConditionFalse, meta.ProgressingReason, message)
+}
+
+// SetReconciliationFailed updates the FluxShardSet to reflect that the
+// reconciliation failed and will be retried.
+func SetReconciliationFailed(set *FluxShardSet, message string) {
+	conditions.MarkReconciling(set, meta.ProgressingWithRetryReason, "%s", message)
+	SetFluxShardSetReadiness(set, metav1.ConditionFalse, ReconciliationFailedReason, message)
+}
+
+// SetStalled updates the FluxShardSet to reflect that the reconciliation
+// failed and won't be retried until the FluxShardSet or its source
+// Deployment changes.
+func SetStalled(set *FluxShardSet, reason, message string) {
+	conditions.MarkStalled(set, reason, "%s", message)
+	SetFluxShardSetReadiness(set, metav1.ConditionFalse, reason, message)
+}
+
+func setInventory(set *FluxShardSet, inventory *ResourceInventory) {
 	set.Status.Inventory = inventory
 
 	if len(inventory.Entries) == 0 {
 		set.Status.Inventory = nil
 	}
-
-	SetFluxShardSetReadiness(set, metav1.ConditionTrue, reason, message)
 }
 
 // FluxShardSetReadiness returns the readiness condition of the FluxShardSet.
 func FluxShardSetReadiness(set *FluxShardSet) metav1.ConditionStatus {
 	return apimeta.FindStatusCondition(set.Status.Conditions, meta.ReadyCondition).Status
 }
+
+// SetOrphanedObjects sets the OrphanedObjects condition and records up to
+// MaxOrphanedObjects of the orphaned objects.
+func SetOrphanedObjects(set *FluxShardSet, orphans []OrphanedObject) {
+	if len(orphans) == 0 {
+		set.Status.OrphanedObjects = nil
+		conditions.MarkFalse(set, OrphanedObjectsCondition, NoOrphanedObjectsReason, "all objects with shard keys are served")
+		return
+	}
+
+	set.Status.OrphanedObjects = orphans
+	if len(orphans) > MaxOrphanedObjects {
+		set.Status.OrphanedObjects = orphans[:MaxOrphanedObjects]
+	}
+	conditions.MarkTrue(set, OrphanedObjectsCondition, OrphanedObjectsFoundReason, "%d object(s) have shard keys that are not served", len(orphans))
+}
+
+// SetDrifted sets the Drifted condition from the descriptions of the shard
+// Deployments that have drifted.
+func SetDrifted(set *FluxShardSet, drifted []string) {
+	if len(drifted) == 0 {
+		conditions.MarkFalse(set, DriftedCondition, NoDriftReason, "all shard Deployments match the generated Deployments")
+		return
+	}
+
+	conditions.MarkTrue(set, DriftedCondition, DriftDetectedReason, "%d Deployment(s) have drifted: %s", len(drifted), strings.Join(drifted, "; "))
+}
+
+// SetRolloutPaused sets the RolloutPaused condition from the descriptions of
+// the shards that failed to roll out.
+func SetRolloutPaused(set *FluxShardSet, failures []string) {
+	if len(failures) == 0 {
+		conditions.MarkFalse(set, RolloutPausedCondition, NoRolloutFailuresReason, "no shard has failed to roll out")
+		return
+	}
+
+	conditions.MarkTrue(set, RolloutPausedCondition, RolloutFailedReason, "%d shard(s) failed to roll out: %s", len(failures), strings.Join(failures, "; "))
+}
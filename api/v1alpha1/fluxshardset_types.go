@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	"github.com/fluxcd/pkg/apis/meta"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -33,17 +34,405 @@ type FluxShardSetSpec struct {
 	// +optional
 	Suspend bool `json:"suspend,omitempty"`
 
+	// Interval is how often the FluxShardSet is reconciled to correct drift,
+	// find orphaned objects and check the health of the shards.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +kubebuilder:default:="5m"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// RetryInterval is how long to wait before reconciling the FluxShardSet
+	// again after a failed reconciliation. If not set, failed reconciliations
+	// are retried with an exponential backoff.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	RetryInterval *metav1.Duration `json:"retryInterval,omitempty"`
+
 	// Reference the source Deployment.
 	SourceDeploymentRef SourceDeploymentReference `json:"sourceDeploymentRef"`
 
+	// SourceMissingPolicy is what happens to the shard Deployments when the
+	// source Deployment is not found.
+	// +kubebuilder:validation:Enum=Keep;ScaleToZero;Delete
+	// +kubebuilder:default:=Keep
+	// +optional
+	SourceMissingPolicy SourceMissingPolicy `json:"sourceMissingPolicy,omitempty"`
+
+	// DeletionPolicy is what happens to the shard Deployments and the Flux
+	// objects assigned to the shards when the FluxShardSet is deleted.
+	// +kubebuilder:validation:Enum=Delete;Orphan;ReassignToMain
+	// +kubebuilder:default:=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// DrainTimeout is how long a removed shard is kept while its Flux objects
+	// are reconciled by their new controller, the shard Deployment is deleted
+	// when the timeout elapses even if objects have not been reconciled.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +kubebuilder:default:="10m"
+	// +optional
+	DrainTimeout *metav1.Duration `json:"drainTimeout,omitempty"`
+
+	// DriftPolicy is what happens when a shard Deployment has been changed
+	// so that it no longer matches the generated Deployment.
+	// +kubebuilder:validation:Enum=Correct;Report
+	// +kubebuilder:default:=Correct
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// ReplicasPolicy is how the replicas of the shard Deployments are
+	// managed when they are not set by the overrides or patches of a shard.
+	// +kubebuilder:validation:Enum=Source;External
+	// +kubebuilder:default:=Source
+	// +optional
+	ReplicasPolicy ReplicasPolicy `json:"replicasPolicy,omitempty"`
+
+	// RecreateStrategy is how shard Deployments are replaced when they can't
+	// be updated because an immutable field e.g. the selector changed.
+	// +kubebuilder:validation:Enum=DeleteFirst;CreateFirst
+	// +kubebuilder:default:=DeleteFirst
+	// +optional
+	RecreateStrategy RecreateStrategy `json:"recreateStrategy,omitempty"`
+
+	// RolloutStrategy updates the shard Deployments in stages when the
+	// generated Deployments change, if not set all the shard Deployments are
+	// updated at once.
+	// +optional
+	RolloutStrategy *RolloutStrategy `json:"rolloutStrategy,omitempty"`
+
 	// Shards is a list of shards to deploy
 	Shards []ShardSpec `json:"shards,omitempty"`
+
+	// Generators generate shards to deploy in addition to the Shards.
+	//
+	// If a generated shard has the same name as a shard in Shards, the shard
+	// from Shards is used.
+	// +optional
+	Generators []ShardGenerator `json:"generators,omitempty"`
+
+	// Patches are applied to the Deployments generated for all shards.
+	// +optional
+	Patches []Patch `json:"patches,omitempty"`
+
+	// Assignment configures labelling unassigned Flux objects with the
+	// sharding.fluxcd.io/key of one of the shards.
+	// +optional
+	Assignment *Assignment `json:"assignment,omitempty"`
+}
+
+// SourceMissingPolicy is the policy for the shard Deployments when the source
+// Deployment is not found.
+type SourceMissingPolicy string
+
+const (
+	// KeepPolicy leaves the shard Deployments unchanged.
+	KeepPolicy SourceMissingPolicy = "Keep"
+
+	// ScaleToZeroPolicy scales the shard Deployments to zero replicas, they
+	// are scaled up again when the source Deployment is recreated.
+	ScaleToZeroPolicy SourceMissingPolicy = "ScaleToZero"
+
+	// DeletePolicy deletes the shard Deployments, they are created again
+	// when the source Deployment is recreated.
+	DeletePolicy SourceMissingPolicy = "Delete"
+)
+
+// DeletionPolicy is the policy for the shard Deployments and the Flux objects
+// assigned to the shards when the FluxShardSet is deleted.
+type DeletionPolicy string
+
+const (
+	// DeleteDeletionPolicy deletes the shard Deployments, the Flux objects
+	// assigned to the shards are left unchanged.
+	DeleteDeletionPolicy DeletionPolicy = "Delete"
+
+	// OrphanDeletionPolicy keeps the shard Deployments, they are no longer
+	// owned by the FluxShardSet.
+	OrphanDeletionPolicy DeletionPolicy = "Orphan"
+
+	// ReassignToMainDeletionPolicy removes the shard key from the Flux objects
+	// assigned to the shards so they are reconciled by the main controller,
+	// and deletes the shard Deployments.
+	ReassignToMainDeletionPolicy DeletionPolicy = "ReassignToMain"
+)
+
+// DriftPolicy is the policy for shard Deployments that have drifted from the
+// generated Deployments.
+type DriftPolicy string
+
+const (
+	// CorrectDriftPolicy applies the generated Deployment to undo the changes.
+	CorrectDriftPolicy DriftPolicy = "Correct"
+
+	// ReportDriftPolicy leaves the changes in place and reports them in the
+	// Drifted condition.
+	ReportDriftPolicy DriftPolicy = "Report"
+)
+
+// ReplicasPolicy is the policy for the replicas of the shard Deployments.
+type ReplicasPolicy string
+
+const (
+	// SourceReplicasPolicy applies the replicas of the source Deployment to
+	// the shard Deployments.
+	SourceReplicasPolicy ReplicasPolicy = "Source"
+
+	// ExternalReplicasPolicy leaves the replicas of the shard Deployments to
+	// other field managers e.g. a HorizontalPodAutoscaler.
+	ExternalReplicasPolicy ReplicasPolicy = "External"
+)
+
+// RecreateStrategy is the strategy for replacing shard Deployments that can't
+// be updated.
+type RecreateStrategy string
+
+const (
+	// DeleteFirstRecreateStrategy deletes the Deployment and waits for its
+	// pods to be removed before creating the new Deployment, the shard is not
+	// running while it is recreated.
+	DeleteFirstRecreateStrategy RecreateStrategy = "DeleteFirst"
+
+	// CreateFirstRecreateStrategy runs the new Deployment under a temporary
+	// name while the Deployment is recreated, the temporary Deployment is
+	// deleted when the recreated Deployment is available.
+	CreateFirstRecreateStrategy RecreateStrategy = "CreateFirst"
+)
+
+// RolloutStrategy configures how changes to the generated Deployments are
+// rolled out to the shards.
+//
+// A shard is rolled out when its Deployment is available, and the Flux objects
+// assigned to the shard have reconciled successfully after a reconciliation is
+// requested. The rollout is paused while a shard fails to roll out, and
+// continues when the shard recovers or the generated Deployments change.
+type RolloutStrategy struct {
+	// CanaryShard is the name of the shard that is updated first, the other
+	// shards are not updated until the canary shard is rolled out.
+	// +optional
+	CanaryShard string `json:"canaryShard,omitempty"`
+
+	// MaxUnavailable is the maximum number of shards that can be updating or
+	// unavailable at the same time.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default:=1
+	// +optional
+	MaxUnavailable int `json:"maxUnavailable,omitempty"`
+}
+
+// AssignmentStrategy is the strategy for picking the shard for an unassigned
+// Flux object.
+type AssignmentStrategy string
+
+const (
+	// RoundRobinStrategy assigns objects to each shard in turn.
+	RoundRobinStrategy AssignmentStrategy = "RoundRobin"
+
+	// LeastLoadedStrategy assigns objects to the shard with the fewest
+	// assigned objects.
+	LeastLoadedStrategy AssignmentStrategy = "LeastLoaded"
+
+	// ConsistentHashStrategy assigns objects to shards by hashing the object
+	// namespace and name, adding or removing a shard moves as few objects as
+	// possible.
+	ConsistentHashStrategy AssignmentStrategy = "ConsistentHash"
+
+	// NamespaceStrategy assigns all the objects in a namespace to the shard
+	// the namespace is mapped to.
+	NamespaceStrategy AssignmentStrategy = "Namespace"
+)
+
+// Assignment configures how unassigned Flux objects are assigned to shards.
+//
+// Objects without the sharding.fluxcd.io/key label, or labelled for a shard
+// that has been removed from the FluxShardSet, are labelled with the name of
+// one of the shards.
+//
+// With the ConsistentHash strategy, objects already labelled for one of the
+// shards are moved when the hash picks a different shard, other strategies
+// don't move objects between existing shards.
+//
+// With the Namespace strategy, objects in the mapped namespaces are always
+// labelled for the mapped shard, and objects in other namespaces are not
+// assigned.
+type Assignment struct {
+	// Strategy is used to pick the shard for each unassigned object.
+	// +kubebuilder:validation:Enum=RoundRobin;LeastLoaded;ConsistentHash;Namespace
+	// +kubebuilder:default:=RoundRobin
+	// +optional
+	Strategy AssignmentStrategy `json:"strategy,omitempty"`
+
+	// Kinds are the Flux kinds to assign e.g. Kustomization, HelmRelease, if
+	// no kinds are provided the kinds reconciled by the Flux controller that
+	// the source Deployment is named for are assigned e.g. Kustomization for
+	// the kustomize-controller. The kinds must be provided if the source
+	// Deployment is not named for a Flux controller.
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+
+	// Selector restricts assignment to Flux objects matching the selector.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Namespaces maps namespaces to shards for the Namespace strategy.
+	//
+	// If a namespace is matched by more than one mapping, the first mapping is
+	// used.
+	// +optional
+	Namespaces []NamespaceAssignment `json:"namespaces,omitempty"`
+}
+
+// NamespaceAssignment maps namespaces to a shard.
+type NamespaceAssignment struct {
+	// Shard is the name of the shard to assign objects in the namespaces to.
+	Shard string `json:"shard"`
+
+	// Namespaces are the names of the namespaces to map.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// NamespaceSelector maps the namespaces matching the selector.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// ShardGenerator generates a list of shards, only one of the generators
+// should be configured.
+type ShardGenerator struct {
+	// Range generates a numbered sequence of shards.
+	// +optional
+	Range *RangeGenerator `json:"range,omitempty"`
+
+	// ConfigMap generates shards from a list of names in a ConfigMap.
+	// +optional
+	ConfigMap *ConfigMapGenerator `json:"configMap,omitempty"`
+
+	// Discovery generates a shard for each distinct sharding.fluxcd.io/key
+	// label value on Flux objects in the cluster.
+	// +optional
+	Discovery *DiscoveryGenerator `json:"discovery,omitempty"`
+}
+
+// RangeGenerator generates Count shards, numbered from Start.
+type RangeGenerator struct {
+	// Start is the number of the first shard.
+	// +optional
+	Start int `json:"start,omitempty"`
+
+	// Count is the number of shards to generate.
+	// +kubebuilder:validation:Minimum=0
+	Count int `json:"count"`
+
+	// NameTemplate is rendered as a Go template for each shard to generate the
+	// shard name, and can refer to the number of the shard with {{ .Index }}.
+	// +kubebuilder:default:="shard-{{ .Index }}"
+	// +optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
+}
+
+// ConfigMapGenerator generates shards from a list of names in a ConfigMap in
+// the same namespace as the FluxShardSet.
+type ConfigMapGenerator struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Key is the key in the ConfigMap data with the shard names, the names can
+	// be separated by commas or whitespace.
+	Key string `json:"key"`
+}
+
+// DiscoveryGenerator generates shards from the sharding.fluxcd.io/key label
+// values on Flux objects in all namespaces.
+//
+// Label values that are not valid shard names are ignored.
+type DiscoveryGenerator struct {
+	// Kinds are the Flux kinds to discover shards from e.g. Kustomization,
+	// HelmRelease, GitRepository, if no kinds are provided all the Flux kinds
+	// installed in the cluster are used.
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+
+	// Selector restricts discovery to Flux objects matching the selector.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// GracePeriod is how long a discovered shard is kept after the last
+	// object with its key is removed.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +kubebuilder:default:="5m"
+	// +optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
 }
 
 // ShardSpec defines a shard to deploy
 type ShardSpec struct {
 	// Name is the name of the shard
 	Name string `json:"name"`
+
+	// Suspend stops the reconciliation of the shard's Deployment, changes made
+	// to the Deployment are not undone and it is not deleted.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Maintenance scales the shard's Deployment to zero replicas, the Flux
+	// objects assigned to the shard are not reconciled and are not moved to
+	// other shards.
+	// +optional
+	Maintenance bool `json:"maintenance,omitempty"`
+
+	// Overrides are applied to the Deployment generated for this shard, on top
+	// of the values copied from the source Deployment.
+	// +optional
+	Overrides *ShardOverrides `json:"overrides,omitempty"`
+
+	// Patches are applied to the Deployment generated for this shard, after
+	// the Patches from the FluxShardSet.
+	// +optional
+	Patches []Patch `json:"patches,omitempty"`
+}
+
+// Patch contains an inline strategic merge patch or JSON6902 patch that is
+// applied to generated Deployments.
+type Patch struct {
+	// Patch contains an inline strategic merge patch, or an inline JSON6902
+	// patch with an array of operation objects.
+	//
+	// The patch is rendered as a Go template before it's applied, and can
+	// refer to {{ .ShardName }} and {{ .ShardSetName }}.
+	// +required
+	Patch string `json:"patch"`
+}
+
+// ShardOverrides defines the values that can be changed for an individual
+// shard.
+//
+// Values in the overrides take precedence over the values copied from the
+// source Deployment.
+type ShardOverrides struct {
+	// Replicas overrides the number of replicas of the shard Deployment.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Resources are merged into the resources of the manager container, limits
+	// and requests in the overrides replace those with the same name.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Args are added to the args of the manager container, an arg for the same
+	// flag as an existing arg replaces it.
+	//
+	// The --watch-label-selector arg is managed by the controller and can't be
+	// overridden.
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// Env is added to the environment of the manager container, a variable
+	// with the same name as an existing variable replaces it.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
 }
 
 // FluxShardSetStatus defines the observed state of FluxShardSet
@@ -63,8 +452,196 @@ type FluxShardSetStatus struct {
 	// have been successfully applied
 	// +optional
 	Inventory *ResourceInventory `json:"inventory,omitempty"`
+
+	// DiscoveredShards are the shards found by discovery generators.
+	// +optional
+	DiscoveredShards []DiscoveredShard `json:"discoveredShards,omitempty"`
+
+	// Shards records the state of each of the shards.
+	// +optional
+	Shards []ShardStatus `json:"shards,omitempty"`
+
+	// OrphanedObjects are Flux objects labelled for shards that are not
+	// served by any FluxShardSet, at most 20 objects are recorded.
+	// +optional
+	OrphanedObjects []OrphanedObject `json:"orphanedObjects,omitempty"`
+
+	// Rollout records the progress of rolling out changes to the shard
+	// Deployments when a rollout strategy is configured.
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+}
+
+// RolloutStatus records the progress of a rollout.
+type RolloutStatus struct {
+	// Revision identifies the generated Deployments that are being rolled
+	// out.
+	Revision string `json:"revision"`
+
+	// UpdatedShards are the shards that have been updated to the revision and
+	// are not yet rolled out.
+	// +optional
+	UpdatedShards []string `json:"updatedShards,omitempty"`
+
+	// PendingShards are the shards that are waiting to be updated.
+	// +optional
+	PendingShards []string `json:"pendingShards,omitempty"`
+}
+
+// OrphanedObject references a Flux object with a shard key that is not served.
+type OrphanedObject struct {
+	// Kind of the object.
+	Kind string `json:"kind"`
+
+	// Namespace of the object.
+	Namespace string `json:"namespace"`
+
+	// Name of the object.
+	Name string `json:"name"`
+
+	// ShardKey is the value of the sharding.fluxcd.io/key label.
+	ShardKey string `json:"shardKey"`
 }
 
+// ShardPhase is the phase of a shard in the FluxShardSet.
+type ShardPhase string
+
+const (
+	// ShardActivePhase is the phase of the shards in the FluxShardSet.
+	ShardActivePhase ShardPhase = "Active"
+
+	// ShardSuspendedPhase is the phase of a shard with a Deployment that is
+	// not reconciled.
+	ShardSuspendedPhase ShardPhase = "Suspended"
+
+	// ShardMaintenancePhase is the phase of a shard with a Deployment that is
+	// scaled to zero for maintenance.
+	ShardMaintenancePhase ShardPhase = "Maintenance"
+
+	// ShardDrainingPhase is the phase of a shard that has been removed from
+	// the FluxShardSet, its Deployment is kept until the Flux objects that
+	// were assigned to it are reconciled by their new controller.
+	ShardDrainingPhase ShardPhase = "Draining"
+)
+
+// RecreatePhase is the step a shard is at while its Deployment is recreated.
+type RecreatePhase string
+
+const (
+	// RecreateStartingTemporaryPhase is waiting for the temporary Deployment
+	// created with the CreateFirst strategy to be available.
+	RecreateStartingTemporaryPhase RecreatePhase = "StartingTemporary"
+
+	// RecreateDeletingPhase is waiting for the old Deployment and its pods to
+	// be deleted.
+	RecreateDeletingPhase RecreatePhase = "Deleting"
+
+	// RecreateStartingPhase is waiting for the recreated Deployment to be
+	// available before the temporary Deployment is deleted.
+	RecreateStartingPhase RecreatePhase = "Starting"
+)
+
+// ShardStatus records the state of a shard.
+type ShardStatus struct {
+	// Name is the name of the shard.
+	Name string `json:"name"`
+
+	// Phase is Active for the shards in the FluxShardSet, Suspended or
+	// Maintenance for shards that are suspended or in maintenance, or Draining
+	// for shards that have been removed and are waiting for their objects to
+	// be reconciled by another controller.
+	// +optional
+	Phase ShardPhase `json:"phase,omitempty"`
+
+	// DrainingSince is when the shard was removed and started draining.
+	// +optional
+	DrainingSince *metav1.Time `json:"drainingSince,omitempty"`
+
+	// AffectedObjects is the number of Flux objects labelled with the shard's
+	// key that are not reconciled while the shard is in maintenance.
+	// +optional
+	AffectedObjects int `json:"affectedObjects,omitempty"`
+
+	// PendingObjects is the number of Flux objects moved from a draining
+	// shard that have not yet been reconciled by their new controller.
+	// +optional
+	PendingObjects int `json:"pendingObjects,omitempty"`
+
+	// AssignedObjects is the number of Flux objects labelled with the
+	// shard's key, this is only counted when assignment is configured.
+	// +optional
+	AssignedObjects int `json:"assignedObjects,omitempty"`
+
+	// DeploymentRef references the Deployment generated for the shard.
+	// +optional
+	DeploymentRef *meta.NamespacedObjectReference `json:"deploymentRef,omitempty"`
+
+	// Replicas is the desired number of replicas of the Deployment.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// ReadyReplicas is the number of ready replicas of the Deployment.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// AvailableReplicas is the number of available replicas of the
+	// Deployment.
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// Image is the image of the manager container in the Deployment.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Health is the status of the Deployment computed by kstatus, one of
+	// Current, InProgress, Failed, Terminating or Unknown.
+	// +optional
+	Health string `json:"health,omitempty"`
+
+	// RestartedAt is the value of the templates.weave.works/restartedAt
+	// annotation on the pod template of the Deployment, from the last restart
+	// requested for the shard.
+	// +optional
+	RestartedAt string `json:"restartedAt,omitempty"`
+
+	// RecreatePhase is the step of recreating the Deployment because an
+	// immutable field e.g. the selector changed, it is not set when the
+	// Deployment isn't being recreated.
+	// +optional
+	RecreatePhase RecreatePhase `json:"recreatePhase,omitempty"`
+
+	// RecreatedAt is when the Deployment was last recreated because an
+	// immutable field e.g. the selector changed.
+	// +optional
+	RecreatedAt *metav1.Time `json:"recreatedAt,omitempty"`
+}
+
+// DiscoveredShard records a shard found by a discovery generator.
+type DiscoveredShard struct {
+	// Name is the name of the shard.
+	Name string `json:"name"`
+
+	// MissingSince is when the last object with the shard key was no longer
+	// found, the shard is removed when the grace period has elapsed.
+	// +optional
+	MissingSince *metav1.Time `json:"missingSince,omitempty"`
+}
+
+// FluxShardSetFinalizer is the finalizer added to FluxShardSets to clean up
+// the shards when the FluxShardSet is deleted.
+const FluxShardSetFinalizer = "templates.weave.works/finalizer"
+
+const (
+	// RestartedAtAnnotation requests a rolling restart of the shard
+	// Deployments when it is added to a FluxShardSet or its value changes, the
+	// value is copied to the pod template of the restarted Deployments.
+	RestartedAtAnnotation = "templates.weave.works/restartedAt"
+
+	// RestartShardsAnnotation restricts the restart requested with the
+	// RestartedAtAnnotation to a comma separated list of shard names.
+	RestartShardsAnnotation = "templates.weave.works/restartShards"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
@@ -79,6 +656,16 @@ type FluxShardSet struct {
 	Status FluxShardSetStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the status conditions of the FluxShardSet.
+func (in FluxShardSet) GetConditions() []metav1.Condition {
+	return in.Status.Conditions
+}
+
+// SetConditions sets the status conditions on the FluxShardSet.
+func (in *FluxShardSet) SetConditions(conditions []metav1.Condition) {
+	in.Status.Conditions = conditions
+}
+
 //+kubebuilder:object:root=true
 
 // FluxShardSetList contains a list of FluxShardSet
@@ -21,6 +21,10 @@ type ResourceRef struct {
 
 	// Version is the API version of the Kubernetes resource object's kind.
 	Version string `json:"v"`
+
+	// Checksum is the checksum of the last applied resource.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
 }
 
 // ResourceRefFromObject returns a ResourceRef from a runtime.Object.
@@ -31,11 +31,17 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	helper "github.com/fluxcd/pkg/runtime/controller"
+	"github.com/fluxcd/pkg/runtime/events"
+
 	templatesv1alpha1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
 	"github.com/weaveworks/flux-shard-controller/internal/controller"
+	"github.com/weaveworks/flux-shard-controller/internal/webhooks"
 	//+kubebuilder:scaffold:imports
 )
 
+const controllerName = "flux-shard-controller"
+
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -52,11 +58,20 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var enableWebhooks bool
+	var shardKeyValidation string
+	var eventsAddr string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the admission webhooks, this requires a serving certificate for the webhook server.")
+	flag.StringVar(&shardKeyValidation, "shard-key-validation", string(webhooks.EnforceValidation),
+		"How Flux objects with shard keys that are not served are handled, \"enforce\" rejects the objects and \"warn\" allows them with a warning.")
+	flag.StringVar(&eventsAddr, "events-addr", "",
+		"The address of the events receiver e.g. the Flux notification-controller, events are only recorded in Kubernetes if not set.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -91,13 +106,42 @@ func main() {
 		os.Exit(1)
 	}
 
+	eventRecorder, err := events.NewRecorder(mgr, ctrl.Log, eventsAddr, controllerName)
+	if err != nil {
+		setupLog.Error(err, "unable to create event recorder")
+		os.Exit(1)
+	}
+
 	if err = (&controller.FluxShardSetReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		EventRecorder: eventRecorder,
+		Metrics:       helper.MustMakeMetrics(mgr),
+		Scheme:        mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "FluxShardSet")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		if err = (&webhooks.ShardKeyMutator{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ShardKeyMutator")
+			os.Exit(1)
+		}
+
+		validationMode, err := webhooks.ParseValidationMode(shardKeyValidation)
+		if err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ShardKeyValidator")
+			os.Exit(1)
+		}
+		if err = (&webhooks.ShardKeyValidator{
+			Client: mgr.GetClient(),
+			Mode:   validationMode,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ShardKeyValidator")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,178 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+)
+
+// Assign labels the unassigned Flux objects that match the assignment with the
+// key of one of the shards, the kinds of the assignment must be resolved with
+// Resolve.
+//
+// Objects assigned to previous shards that are no longer in shards are
+// reassigned, and with the ConsistentHash strategy, objects assigned to shards
+// are moved if their hash now picks a different shard.
+//
+// The number of objects assigned to each shard is returned, including objects
+// that were already assigned.
+func Assign(ctx context.Context, c client.Client, assignment *v1alpha1.Assignment, shards, previous []string) (map[string]int, error) {
+	counts := map[string]int{}
+	for _, shard := range shards {
+		counts[shard] = 0
+	}
+	removed := map[string]bool{}
+	for _, shard := range previous {
+		if _, ok := counts[shard]; !ok {
+			removed[shard] = true
+		}
+	}
+
+	objects, err := listObjects(ctx, c, assignment)
+	if err != nil {
+		return nil, err
+	}
+
+	if assignment.Strategy == v1alpha1.NamespaceStrategy {
+		return assignByNamespace(ctx, c, assignment.Namespaces, objects, counts)
+	}
+
+	unassigned := []metav1.PartialObjectMetadata{}
+	for _, obj := range objects {
+		key, ok := obj.GetLabels()[deploys.ShardKeyLabel]
+		if !ok || removed[key] {
+			unassigned = append(unassigned, obj)
+			continue
+		}
+		if _, ok := counts[key]; !ok {
+			continue
+		}
+		if assignment.Strategy == v1alpha1.ConsistentHashStrategy && len(shards) > 0 && rendezvous(shards, &obj) != key {
+			unassigned = append(unassigned, obj)
+			continue
+		}
+		counts[key]++
+	}
+
+	if len(shards) == 0 || len(unassigned) == 0 {
+		return counts, nil
+	}
+
+	// Sorting the objects means that the same objects are assigned to the same
+	// shards if the labelling fails part way through.
+	sort.Slice(unassigned, func(i, j int) bool {
+		return objectKey(&unassigned[i]) < objectKey(&unassigned[j])
+	})
+
+	picker, err := newPicker(assignment.Strategy, shards, counts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range unassigned {
+		obj := &unassigned[i]
+		shard := picker(obj)
+		if err := setShardKey(ctx, c, obj, shard); err != nil {
+			return nil, err
+		}
+		counts[shard]++
+	}
+
+	return counts, nil
+}
+
+// listObjects returns the Flux objects that match the kinds and selector of the
+// assignment, the assignment must list its kinds.
+func listObjects(ctx context.Context, c client.Client, assignment *v1alpha1.Assignment) ([]metav1.PartialObjectMetadata, error) {
+	kinds, err := parseKinds(assignment.Kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := labels.Everything()
+	if assignment.Selector != nil {
+		selector, err = metav1.LabelSelectorAsSelector(assignment.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse selector: %w", err)
+		}
+	}
+
+	return fluxobjects.List(ctx, c, kinds, client.MatchingLabelsSelector{Selector: selector})
+}
+
+// picker returns the shard to assign an object to.
+type picker func(obj client.Object) string
+
+func newPicker(strategy v1alpha1.AssignmentStrategy, shards []string, counts map[string]int) (picker, error) {
+	switch strategy {
+	case "", v1alpha1.RoundRobinStrategy:
+		return roundRobin(shards, counts), nil
+	case v1alpha1.LeastLoadedStrategy:
+		return leastLoaded(shards, counts), nil
+	case v1alpha1.ConsistentHashStrategy:
+		return func(obj client.Object) string {
+			return rendezvous(shards, obj)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown assignment strategy %q", strategy)
+}
+
+// roundRobin assigns objects to each shard in turn, continuing from the number
+// of objects that are already assigned.
+func roundRobin(shards []string, counts map[string]int) picker {
+	next := 0
+	for _, count := range counts {
+		next += count
+	}
+
+	return func(client.Object) string {
+		shard := shards[next%len(shards)]
+		next++
+
+		return shard
+	}
+}
+
+// leastLoaded assigns objects to the shard with the fewest objects, the
+// earliest shard is used if more than one shard has the fewest objects.
+func leastLoaded(shards []string, counts map[string]int) picker {
+	return func(client.Object) string {
+		shard := shards[0]
+		for _, candidate := range shards[1:] {
+			if counts[candidate] < counts[shard] {
+				shard = candidate
+			}
+		}
+
+		return shard
+	}
+}
+
+func setShardKey(ctx context.Context, c client.Client, obj *metav1.PartialObjectMetadata, shard string) error {
+	patch := client.MergeFrom(obj.DeepCopy())
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[deploys.ShardKeyLabel] = shard
+	obj.SetLabels(labels)
+
+	if err := c.Patch(ctx, obj, patch); err != nil {
+		return fmt.Errorf("failed to assign %s %s to shard %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, client.ObjectKeyFromObject(obj), shard, err)
+	}
+
+	return nil
+}
+
+func objectKey(obj client.Object) string {
+	return obj.GetObjectKind().GroupVersionKind().Kind + "/" + client.ObjectKeyFromObject(obj).String()
+}
@@ -0,0 +1,124 @@
+package assignment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestAssign(t *testing.T) {
+	tests := []struct {
+		name       string
+		assignment templatesv1.Assignment
+		objects    []client.Object
+		wantCounts map[string]int
+		wantKeys   map[string]string
+	}{
+		{
+			name:       "round robin",
+			assignment: templatesv1.Assignment{Strategy: templatesv1.RoundRobinStrategy, Kinds: []string{"Kustomization"}},
+			objects: []client.Object{
+				test.NewFluxObject("Kustomization", "default", "app-1", nil),
+				test.NewFluxObject("Kustomization", "default", "app-2", nil),
+				test.NewFluxObject("Kustomization", "default", "app-3", nil),
+			},
+			wantCounts: map[string]int{"shard-a": 2, "shard-b": 1},
+			wantKeys: map[string]string{
+				"app-1": "shard-a",
+				"app-2": "shard-b",
+				"app-3": "shard-a",
+			},
+		},
+		{
+			name:       "least loaded",
+			assignment: templatesv1.Assignment{Strategy: templatesv1.LeastLoadedStrategy, Kinds: []string{"Kustomization", "HelmRelease"}},
+			objects: []client.Object{
+				test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+				test.NewFluxObject("Kustomization", "default", "app-2", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+				test.NewFluxObject("Kustomization", "default", "app-3", nil),
+				test.NewFluxObject("HelmRelease", "default", "app-4", nil),
+				test.NewFluxObject("HelmRelease", "default", "app-5", nil),
+			},
+			wantCounts: map[string]int{"shard-a": 3, "shard-b": 2},
+			wantKeys: map[string]string{
+				"app-1": "shard-a",
+				"app-2": "shard-a",
+				"app-3": "shard-a",
+				"app-4": "shard-b",
+				"app-5": "shard-b",
+			},
+		},
+		{
+			name:       "objects assigned to other shards are not changed",
+			assignment: templatesv1.Assignment{Kinds: []string{"Kustomization"}},
+			objects: []client.Object{
+				test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "other"}),
+				test.NewFluxObject("Kustomization", "default", "app-2", nil),
+			},
+			wantCounts: map[string]int{"shard-a": 1, "shard-b": 0},
+			wantKeys: map[string]string{
+				"app-1": "other",
+				"app-2": "shard-a",
+			},
+		},
+		{
+			name: "restricted kinds and selector",
+			assignment: templatesv1.Assignment{
+				Kinds: []string{"Kustomization"},
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"team": "a"},
+				},
+			},
+			objects: []client.Object{
+				test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{"team": "a"}),
+				test.NewFluxObject("Kustomization", "default", "app-2", nil),
+				test.NewFluxObject("HelmRelease", "default", "app-3", map[string]string{"team": "a"}),
+			},
+			wantCounts: map[string]int{"shard-a": 1, "shard-b": 0},
+			wantKeys: map[string]string{
+				"app-1": "shard-a",
+				"app-2": "",
+				"app-3": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := test.NewFakeFluxClient(tt.objects...)
+
+			counts, err := Assign(context.TODO(), cl, &tt.assignment, []string{"shard-a", "shard-b"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.wantCounts, counts); diff != "" {
+				t.Errorf("failed to count assigned objects:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.wantKeys, shardKeys(t, cl, tt.objects)); diff != "" {
+				t.Errorf("failed to assign objects:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAssign_noShards(t *testing.T) {
+	cl := test.NewFakeFluxClient(test.NewFluxObject("Kustomization", "default", "app-1", nil))
+
+	counts, err := Assign(context.TODO(), cl, &templatesv1.Assignment{Kinds: []string{"Kustomization"}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(map[string]int{}, counts); diff != "" {
+		t.Fatalf("failed to count assigned objects:\n%s", diff)
+	}
+}
@@ -0,0 +1,41 @@
+package assignment
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+)
+
+// Count returns the number of Flux objects matching the assignment that are
+// labelled with the key of each of the shards, keyed by shard and then by kind,
+// and the number of matching objects without a shard key.
+//
+// The kinds of the assignment must be resolved with Resolve.
+func Count(ctx context.Context, c client.Client, assignment *v1alpha1.Assignment, shards []string) (map[string]map[string]int, int, error) {
+	counts := map[string]map[string]int{}
+	for _, shard := range shards {
+		counts[shard] = map[string]int{}
+	}
+
+	objects, err := listObjects(ctx, c, assignment)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unassigned := 0
+	for _, obj := range objects {
+		key, ok := obj.GetLabels()[deploys.ShardKeyLabel]
+		if !ok {
+			unassigned++
+			continue
+		}
+		if kinds, ok := counts[key]; ok {
+			kinds[obj.GetObjectKind().GroupVersionKind().Kind]++
+		}
+	}
+
+	return counts, unassigned, nil
+}
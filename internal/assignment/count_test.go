@@ -0,0 +1,74 @@
+package assignment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestCount(t *testing.T) {
+	objects := []client.Object{
+		test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+		test.NewFluxObject("Kustomization", "default", "app-2", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+		test.NewFluxObject("HelmRelease", "default", "app-3", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+		test.NewFluxObject("HelmRelease", "default", "app-4", map[string]string{deploys.ShardKeyLabel: "other"}),
+		test.NewFluxObject("HelmRelease", "default", "app-5", nil),
+	}
+
+	tests := []struct {
+		name           string
+		assignment     *templatesv1.Assignment
+		wantCounts     map[string]map[string]int
+		wantUnassigned int
+	}{
+		{
+			name:       "multiple kinds",
+			assignment: &templatesv1.Assignment{Kinds: []string{"Kustomization", "HelmRelease"}},
+			wantCounts: map[string]map[string]int{
+				"shard-a": {"Kustomization": 2, "HelmRelease": 1},
+				"shard-b": {},
+			},
+			wantUnassigned: 1,
+		},
+		{
+			name:       "restricted kinds",
+			assignment: &templatesv1.Assignment{Kinds: []string{"Kustomization"}},
+			wantCounts: map[string]map[string]int{
+				"shard-a": {"Kustomization": 2},
+				"shard-b": {},
+			},
+			wantUnassigned: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := test.NewFakeFluxClient(objects...)
+
+			counts, unassigned, err := Count(context.TODO(), cl, tt.assignment, []string{"shard-a", "shard-b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.wantCounts, counts); diff != "" {
+				t.Errorf("failed to count objects:\n%s", diff)
+			}
+			if unassigned != tt.wantUnassigned {
+				t.Errorf("got %d unassigned objects, want %d", unassigned, tt.wantUnassigned)
+			}
+		})
+	}
+}
+
+func TestCount_noKinds(t *testing.T) {
+	cl := test.NewFakeFluxClient()
+
+	_, _, err := Count(context.TODO(), cl, &templatesv1.Assignment{}, []string{"shard-a"})
+	test.AssertErrorMatch(t, "no kinds to assign", err)
+}
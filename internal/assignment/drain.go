@@ -0,0 +1,140 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+
+	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+)
+
+// Drain moves the Flux objects of the kinds labelled with the key of the
+// draining shard to the shards picked by the assignment, and requests the
+// reconciliation of each moved object with requestedAt.
+//
+// Objects are moved to the main controller by removing the shard key if the
+// assignment is nil, or the assignment doesn't pick a shard for the object.
+//
+// The number of objects moved is returned.
+func Drain(ctx context.Context, c client.Client, kinds []string, assignment *v1alpha1.Assignment, shards []v1alpha1.ShardStatus, draining, requestedAt string) (int, error) {
+	gks, err := parseKinds(kinds)
+	if err != nil {
+		return 0, err
+	}
+
+	objects, err := fluxobjects.List(ctx, c, gks, client.MatchingLabels{deploys.ShardKeyLabel: draining})
+	if err != nil {
+		return 0, err
+	}
+
+	// The shards are copied so the counts can be updated as objects are
+	// moved.
+	shards = append([]v1alpha1.ShardStatus{}, shards...)
+
+	for i := range objects {
+		obj := &objects[i]
+		shard, err := drainTo(ctx, c, assignment, shards, obj)
+		if err != nil {
+			return i, err
+		}
+
+		if err := moveObject(ctx, c, obj, shard, requestedAt); err != nil {
+			return i, err
+		}
+
+		for j := range shards {
+			if shards[j].Name == shard {
+				shards[j].AssignedObjects++
+			}
+		}
+	}
+
+	return len(objects), nil
+}
+
+// Pending returns the number of Flux objects of the kinds with a
+// reconciliation requested at requestedAt that have not handled the request.
+//
+// Suspended objects are not counted, they won't be reconciled until they are
+// resumed.
+func Pending(ctx context.Context, c client.Client, kinds []string, requestedAt string) (int, error) {
+	gks, err := parseKinds(kinds)
+	if err != nil {
+		return 0, err
+	}
+
+	objects, err := fluxobjects.List(ctx, c, gks)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for i := range objects {
+		obj := &objects[i]
+		if obj.GetAnnotations()[fluxMeta.ReconcileRequestAnnotation] != requestedAt {
+			continue
+		}
+
+		u, err := getObject(ctx, c, obj)
+		if err != nil {
+			return 0, err
+		}
+
+		if suspended, _, _ := unstructured.NestedBool(u.Object, "spec", "suspend"); suspended {
+			continue
+		}
+		if handled, _, _ := unstructured.NestedString(u.Object, "status", "lastHandledReconcileAt"); handled != requestedAt {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
+
+// drainTo returns the shard to move an object from a draining shard to, or an
+// empty string to move it to the main controller.
+func drainTo(ctx context.Context, c client.Client, assignment *v1alpha1.Assignment, shards []v1alpha1.ShardStatus, obj client.Object) (string, error) {
+	if assignment == nil {
+		return "", nil
+	}
+
+	ok, err := Matches(assignment, obj)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	return PickShard(ctx, c, assignment, shards, obj)
+}
+
+// moveObject labels the object with the key of the shard, or removes the key
+// if the shard is empty, and requests reconciliation in the same patch so the
+// request is handled by the new controller.
+func moveObject(ctx context.Context, c client.Client, obj *metav1.PartialObjectMetadata, shard, requestedAt string) error {
+	patch := client.MergeFrom(obj.DeepCopy())
+	labels := obj.GetLabels()
+	if shard == "" {
+		delete(labels, deploys.ShardKeyLabel)
+	} else {
+		labels[deploys.ShardKeyLabel] = shard
+	}
+	obj.SetLabels(labels)
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[fluxMeta.ReconcileRequestAnnotation] = requestedAt
+	obj.SetAnnotations(annotations)
+
+	if err := c.Patch(ctx, obj, patch); err != nil {
+		return fmt.Errorf("failed to move %s %s to shard %q: %w", obj.GetObjectKind().GroupVersionKind().Kind, client.ObjectKeyFromObject(obj), shard, err)
+	}
+
+	return nil
+}
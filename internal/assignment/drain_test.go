@@ -0,0 +1,103 @@
+package assignment
+
+import (
+	"context"
+	"testing"
+
+	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestDrain(t *testing.T) {
+	tests := []struct {
+		name       string
+		assignment *templatesv1.Assignment
+		wantKeys   map[string]string
+	}{
+		{
+			name: "no assignment moves objects to the main controller",
+			wantKeys: map[string]string{
+				"app-1": "",
+				"app-2": "",
+				"app-3": "shard-b",
+				"repo":  "shard-c",
+			},
+		},
+		{
+			name:       "assignment moves objects to the remaining shards",
+			assignment: &templatesv1.Assignment{Strategy: templatesv1.LeastLoadedStrategy, Kinds: []string{"Kustomization"}},
+			wantKeys: map[string]string{
+				"app-1": "shard-a",
+				"app-2": "shard-a",
+				"app-3": "shard-b",
+				"repo":  "shard-c",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []client.Object{
+				test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "shard-c"}),
+				test.NewFluxObject("Kustomization", "default", "app-2", map[string]string{deploys.ShardKeyLabel: "shard-c"}),
+				test.NewFluxObject("Kustomization", "default", "app-3", map[string]string{deploys.ShardKeyLabel: "shard-b"}),
+				test.NewFluxObject("GitRepository", "default", "repo", map[string]string{deploys.ShardKeyLabel: "shard-c"}),
+			}
+			cl := test.NewFakeFluxClient(objects...)
+			shards := []templatesv1.ShardStatus{
+				{Name: "shard-a", AssignedObjects: 0},
+				{Name: "shard-b", AssignedObjects: 1},
+				{Name: "shard-c", Phase: templatesv1.ShardDrainingPhase, AssignedObjects: 2},
+			}
+
+			moved, err := Drain(context.TODO(), cl, []string{"Kustomization"}, tt.assignment, shards, "shard-c", "drain-1")
+			test.AssertNoError(t, err)
+			if moved != 2 {
+				t.Errorf("got %d moved objects, want 2", moved)
+			}
+
+			if diff := cmp.Diff(tt.wantKeys, shardKeys(t, cl, objects)); diff != "" {
+				t.Errorf("failed to drain objects:\n%s", diff)
+			}
+
+			pending, err := Pending(context.TODO(), cl, []string{"Kustomization"}, "drain-1")
+			test.AssertNoError(t, err)
+			if pending != 2 {
+				t.Errorf("got %d pending objects, want 2", pending)
+			}
+		})
+	}
+}
+
+func TestPending(t *testing.T) {
+	handled := test.NewFluxObject("Kustomization", "default", "app-1", nil)
+	handled.SetAnnotations(map[string]string{fluxMeta.ReconcileRequestAnnotation: "drain-1"})
+	test.AssertNoError(t, unstructured.SetNestedField(handled.Object, "drain-1", "status", "lastHandledReconcileAt"))
+
+	suspended := test.NewFluxObject("Kustomization", "default", "app-2", nil)
+	suspended.SetAnnotations(map[string]string{fluxMeta.ReconcileRequestAnnotation: "drain-1"})
+	test.AssertNoError(t, unstructured.SetNestedField(suspended.Object, true, "spec", "suspend"))
+
+	notHandled := test.NewFluxObject("HelmRelease", "default", "app-3", nil)
+	notHandled.SetAnnotations(map[string]string{fluxMeta.ReconcileRequestAnnotation: "drain-1"})
+
+	otherRequest := test.NewFluxObject("HelmRelease", "default", "app-4", nil)
+	otherRequest.SetAnnotations(map[string]string{fluxMeta.ReconcileRequestAnnotation: "other"})
+
+	otherKind := test.NewFluxObject("GitRepository", "default", "repo", nil)
+	otherKind.SetAnnotations(map[string]string{fluxMeta.ReconcileRequestAnnotation: "drain-1"})
+
+	cl := test.NewFakeFluxClient(handled, suspended, notHandled, otherRequest, otherKind)
+
+	pending, err := Pending(context.TODO(), cl, []string{"Kustomization", "HelmRelease"}, "drain-1")
+	test.AssertNoError(t, err)
+	if pending != 1 {
+		t.Fatalf("got %d pending objects, want 1", pending)
+	}
+}
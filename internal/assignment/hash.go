@@ -0,0 +1,49 @@
+package assignment
+
+import (
+	"hash/fnv"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rendezvous picks the shard with the highest hash for the object's
+// namespace/name.
+//
+// Adding a shard only moves the objects whose highest hash is for the new
+// shard, which is roughly 1/N of the objects, and removing a shard only moves
+// the objects that were assigned to it.
+func rendezvous(shards []string, obj client.Object) string {
+	key := client.ObjectKeyFromObject(obj).String()
+	var picked string
+	var highest uint64
+	for i, shard := range shards {
+		weight := hashWeight(shard, key)
+		if i == 0 || weight > highest {
+			picked = shard
+			highest = weight
+		}
+	}
+
+	return picked
+}
+
+func hashWeight(shard, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(shard))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+
+	return mix(h.Sum64())
+}
+
+// mix is the splitmix64 finalizer, FNV alone doesn't spread similar keys
+// evenly enough for the weights to be compared.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
@@ -0,0 +1,135 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestRendezvous_addingShardMovesMinimalObjects(t *testing.T) {
+	const objectCount = 2000
+	before := []string{"shard-0", "shard-1", "shard-2", "shard-3"}
+	after := append(before, "shard-4")
+
+	moved := 0
+	for i := 0; i < objectCount; i++ {
+		obj := test.NewFluxObject("Kustomization", fmt.Sprintf("ns-%d", i%17), fmt.Sprintf("app-%d", i), nil)
+		from, to := rendezvous(before, obj), rendezvous(after, obj)
+		if from == to {
+			continue
+		}
+		if to != "shard-4" {
+			t.Fatalf("object %s moved from %s to %s, objects should only move to the new shard", client.ObjectKeyFromObject(obj), from, to)
+		}
+		moved++
+	}
+
+	// Ideally 1/5 of the objects move to the new shard.
+	want := objectCount / len(after)
+	if moved < want*3/4 || moved > want*5/4 {
+		t.Fatalf("%d of %d objects moved, want roughly %d", moved, objectCount, want)
+	}
+}
+
+func TestRendezvous_spreadsObjects(t *testing.T) {
+	const objectCount = 2000
+	shards := []string{"shard-0", "shard-1", "shard-2", "shard-3"}
+
+	counts := map[string]int{}
+	for i := 0; i < objectCount; i++ {
+		counts[rendezvous(shards, test.NewFluxObject("Kustomization", "default", fmt.Sprintf("app-%d", i), nil))]++
+	}
+
+	want := objectCount / len(shards)
+	for _, shard := range shards {
+		if c := counts[shard]; c < want*3/4 || c > want*5/4 {
+			t.Errorf("shard %s has %d objects, want roughly %d", shard, c, want)
+		}
+	}
+}
+
+func TestAssign_consistentHash(t *testing.T) {
+	objects := []client.Object{}
+	for i := 0; i < 200; i++ {
+		objects = append(objects, test.NewFluxObject("Kustomization", "default", fmt.Sprintf("app-%d", i), nil))
+	}
+	cl := test.NewFakeFluxClient(objects...)
+	assignment := &templatesv1.Assignment{Strategy: templatesv1.ConsistentHashStrategy, Kinds: []string{"Kustomization"}}
+
+	before := []string{"shard-0", "shard-1", "shard-2", "shard-3"}
+	if _, err := Assign(context.TODO(), cl, assignment, before, nil); err != nil {
+		t.Fatal(err)
+	}
+	assigned := shardKeys(t, cl, objects)
+
+	after := append(before, "shard-4")
+	counts, err := Assign(context.TODO(), cl, assignment, after, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reassigned := shardKeys(t, cl, objects)
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total != len(objects) {
+		t.Fatalf("got %d assigned objects, want %d", total, len(objects))
+	}
+
+	for name, key := range reassigned {
+		if assigned[name] != key && key != "shard-4" {
+			t.Errorf("object %s moved from %s to %s", name, assigned[name], key)
+		}
+	}
+	if counts["shard-4"] == 0 {
+		t.Errorf("no objects were moved to the new shard")
+	}
+}
+
+func TestAssign_reassignsRemovedShards(t *testing.T) {
+	objects := []client.Object{
+		test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+		test.NewFluxObject("Kustomization", "default", "app-2", map[string]string{deploys.ShardKeyLabel: "shard-b"}),
+		test.NewFluxObject("Kustomization", "default", "app-3", map[string]string{deploys.ShardKeyLabel: "other"}),
+	}
+	cl := test.NewFakeFluxClient(objects...)
+
+	counts, err := Assign(context.TODO(), cl, &templatesv1.Assignment{Kinds: []string{"Kustomization"}}, []string{"shard-a"}, []string{"shard-a", "shard-b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(map[string]int{"shard-a": 2}, counts); diff != "" {
+		t.Errorf("failed to count assigned objects:\n%s", diff)
+	}
+	want := map[string]string{
+		"app-1": "shard-a",
+		"app-2": "shard-a",
+		"app-3": "other",
+	}
+	if diff := cmp.Diff(want, shardKeys(t, cl, objects)); diff != "" {
+		t.Errorf("failed to reassign objects:\n%s", diff)
+	}
+}
+
+func shardKeys(t *testing.T, cl client.Client, objects []client.Object) map[string]string {
+	t.Helper()
+	keys := map[string]string{}
+	for _, obj := range objects {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+		test.AssertNoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(obj), u))
+		keys[u.GetName()] = u.GetLabels()[deploys.ShardKeyLabel]
+	}
+
+	return keys
+}
@@ -0,0 +1,58 @@
+package assignment
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+)
+
+// Kinds returns the names of the kinds of Flux objects that are assigned to
+// the shards of the FluxShardSet.
+//
+// These are the kinds of the assignment, or if no kinds are listed, the kinds
+// reconciled by the Flux controller that the source Deployment is named for
+// e.g. Kustomization for the kustomize-controller.
+func Kinds(set *v1alpha1.FluxShardSet) ([]string, error) {
+	if set.Spec.Assignment != nil && len(set.Spec.Assignment.Kinds) > 0 {
+		return set.Spec.Assignment.Kinds, nil
+	}
+
+	kinds, ok := fluxobjects.ControllerKinds[set.Spec.SourceDeploymentRef.Name]
+	if !ok {
+		return nil, fmt.Errorf("source Deployment %s is not a Flux controller, the kinds must be listed in the assignment", set.Spec.SourceDeploymentRef.Name)
+	}
+
+	return kinds, nil
+}
+
+// Resolve returns a copy of the assignment of the FluxShardSet with the kinds
+// returned by Kinds, or nil if the FluxShardSet has no assignment.
+func Resolve(set *v1alpha1.FluxShardSet) (*v1alpha1.Assignment, error) {
+	if set.Spec.Assignment == nil {
+		return nil, nil
+	}
+
+	kinds, err := Kinds(set)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := set.Spec.Assignment.DeepCopy()
+	resolved.Kinds = kinds
+
+	return resolved, nil
+}
+
+// parseKinds returns the GroupKinds for the named kinds, unlike
+// fluxobjects.ParseKinds at least one kind must be named.
+func parseKinds(names []string) ([]schema.GroupKind, error) {
+	if len(names) == 0 {
+		return nil, errors.New("no kinds to assign")
+	}
+
+	return fluxobjects.ParseKinds(names)
+}
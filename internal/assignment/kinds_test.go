@@ -0,0 +1,86 @@
+package assignment
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestKinds(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		assignment *templatesv1.Assignment
+		want       []string
+	}{
+		{
+			name:   "no assignment uses the kinds of the source controller",
+			source: "source-controller",
+			want:   []string{"GitRepository", "OCIRepository", "HelmRepository", "HelmChart", "Bucket"},
+		},
+		{
+			name:       "assignment without kinds uses the kinds of the source controller",
+			source:     "kustomize-controller",
+			assignment: &templatesv1.Assignment{Strategy: templatesv1.LeastLoadedStrategy},
+			want:       []string{"Kustomization"},
+		},
+		{
+			name:       "assignment kinds",
+			source:     "my-controller",
+			assignment: &templatesv1.Assignment{Kinds: []string{"HelmRelease"}},
+			want:       []string{"HelmRelease"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &templatesv1.FluxShardSet{
+				Spec: templatesv1.FluxShardSetSpec{
+					SourceDeploymentRef: templatesv1.SourceDeploymentReference{Name: tt.source},
+					Assignment:          tt.assignment,
+				},
+			}
+
+			kinds, err := Kinds(set)
+			test.AssertNoError(t, err)
+			if diff := cmp.Diff(tt.want, kinds); diff != "" {
+				t.Errorf("failed to get kinds:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKinds_unknownController(t *testing.T) {
+	set := &templatesv1.FluxShardSet{
+		Spec: templatesv1.FluxShardSetSpec{
+			SourceDeploymentRef: templatesv1.SourceDeploymentReference{Name: "my-controller"},
+			Assignment:          &templatesv1.Assignment{},
+		},
+	}
+
+	_, err := Kinds(set)
+	test.AssertErrorMatch(t, "source Deployment my-controller is not a Flux controller, the kinds must be listed in the assignment", err)
+}
+
+func TestResolve(t *testing.T) {
+	set := &templatesv1.FluxShardSet{
+		Spec: templatesv1.FluxShardSetSpec{
+			SourceDeploymentRef: templatesv1.SourceDeploymentReference{Name: "helm-controller"},
+			Assignment:          &templatesv1.Assignment{Strategy: templatesv1.ConsistentHashStrategy},
+		},
+	}
+
+	resolved, err := Resolve(set)
+	test.AssertNoError(t, err)
+
+	want := &templatesv1.Assignment{Strategy: templatesv1.ConsistentHashStrategy, Kinds: []string{"HelmRelease"}}
+	if diff := cmp.Diff(want, resolved); diff != "" {
+		t.Errorf("failed to resolve assignment:\n%s", diff)
+	}
+	if set.Spec.Assignment.Kinds != nil {
+		t.Errorf("resolving the assignment changed the FluxShardSet")
+	}
+}
@@ -0,0 +1,80 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+)
+
+// assignByNamespace labels the objects in the mapped namespaces with the key
+// of the mapped shard, replacing any existing key.
+//
+// Objects in namespaces that are not mapped are counted but not changed.
+func assignByNamespace(ctx context.Context, c client.Client, mappings []v1alpha1.NamespaceAssignment, objects []metav1.PartialObjectMetadata, counts map[string]int) (map[string]int, error) {
+	namespaceShards, err := mapNamespaces(ctx, c, mappings, counts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+		key := obj.GetLabels()[deploys.ShardKeyLabel]
+		shard, ok := namespaceShards[obj.GetNamespace()]
+		if !ok {
+			if _, ok := counts[key]; ok {
+				counts[key]++
+			}
+			continue
+		}
+
+		if key != shard {
+			if err := setShardKey(ctx, c, obj, shard); err != nil {
+				return nil, err
+			}
+		}
+		counts[shard]++
+	}
+
+	return counts, nil
+}
+
+// mapNamespaces returns the shard for each of the mapped namespaces.
+func mapNamespaces(ctx context.Context, c client.Client, mappings []v1alpha1.NamespaceAssignment, counts map[string]int) (map[string]string, error) {
+	namespaceShards := map[string]string{}
+	for i, mapping := range mappings {
+		if _, ok := counts[mapping.Shard]; !ok {
+			return nil, fmt.Errorf("namespace mapping %d refers to unknown shard %q", i, mapping.Shard)
+		}
+
+		for _, ns := range mapping.Namespaces {
+			if _, ok := namespaceShards[ns]; !ok {
+				namespaceShards[ns] = mapping.Shard
+			}
+		}
+
+		if mapping.NamespaceSelector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(mapping.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse namespace selector for mapping %d: %w", i, err)
+		}
+		var namespaces corev1.NamespaceList
+		if err := c.List(ctx, &namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, ns := range namespaces.Items {
+			if _, ok := namespaceShards[ns.Name]; !ok {
+				namespaceShards[ns.Name] = mapping.Shard
+			}
+		}
+	}
+
+	return namespaceShards, nil
+}
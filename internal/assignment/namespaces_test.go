@@ -0,0 +1,88 @@
+package assignment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestAssign_namespaces(t *testing.T) {
+	objects := []client.Object{
+		test.NewFluxObject("Kustomization", "team-a", "app-1", nil),
+		test.NewFluxObject("HelmRelease", "team-a", "app-2", map[string]string{deploys.ShardKeyLabel: "shard-b"}),
+		test.NewFluxObject("Kustomization", "team-b", "app-3", nil),
+		test.NewFluxObject("Kustomization", "team-c", "app-4", nil),
+		test.NewFluxObject("Kustomization", "other", "app-5", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+		test.NewFluxObject("Kustomization", "other", "app-6", nil),
+	}
+	namespaces := []client.Object{
+		test.NewNamespace("team-a"),
+		test.NewNamespace("team-b", func(ns *corev1.Namespace) {
+			ns.Labels = map[string]string{"example.com/tier": "heavy"}
+		}),
+		test.NewNamespace("team-c", func(ns *corev1.Namespace) {
+			ns.Labels = map[string]string{"example.com/tier": "heavy"}
+		}),
+		test.NewNamespace("other"),
+	}
+	cl := test.NewFakeFluxClient(append(objects, namespaces...)...)
+
+	assignment := &templatesv1.Assignment{
+		Strategy: templatesv1.NamespaceStrategy,
+		Kinds:    []string{"Kustomization", "HelmRelease"},
+		Namespaces: []templatesv1.NamespaceAssignment{
+			{
+				Shard:      "shard-a",
+				Namespaces: []string{"team-a", "team-c"},
+			},
+			{
+				Shard: "shard-b",
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"example.com/tier": "heavy"},
+				},
+			},
+		},
+	}
+
+	counts, err := Assign(context.TODO(), cl, assignment, []string{"shard-a", "shard-b"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(map[string]int{"shard-a": 4, "shard-b": 1}, counts); diff != "" {
+		t.Errorf("failed to count assigned objects:\n%s", diff)
+	}
+	want := map[string]string{
+		"app-1": "shard-a",
+		"app-2": "shard-a",
+		"app-3": "shard-b",
+		"app-4": "shard-a",
+		"app-5": "shard-a",
+		"app-6": "",
+	}
+	if diff := cmp.Diff(want, shardKeys(t, cl, objects)); diff != "" {
+		t.Errorf("failed to assign objects:\n%s", diff)
+	}
+}
+
+func TestAssign_namespacesUnknownShard(t *testing.T) {
+	cl := test.NewFakeFluxClient()
+	assignment := &templatesv1.Assignment{
+		Strategy: templatesv1.NamespaceStrategy,
+		Kinds:    []string{"Kustomization"},
+		Namespaces: []templatesv1.NamespaceAssignment{
+			{Shard: "missing", Namespaces: []string{"team-a"}},
+		},
+	}
+
+	_, err := Assign(context.TODO(), cl, assignment, []string{"shard-a"}, nil)
+	test.AssertErrorMatch(t, `namespace mapping 0 refers to unknown shard "missing"`, err)
+}
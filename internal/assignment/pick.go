@@ -0,0 +1,62 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+)
+
+// Matches returns true if the object is one of the kinds and matches the
+// selector of the assignment, the kinds of the assignment must be resolved with
+// Resolve.
+func Matches(assignment *v1alpha1.Assignment, obj client.Object) (bool, error) {
+	return fluxobjects.Matches(assignment.Kinds, assignment.Selector, obj)
+}
+
+// PickShard returns the shard to assign a new object to, or an empty string if
+// the object should not be assigned.
+//
+// The number of objects assigned to each shard is taken from the shard
+// statuses, the RoundRobin and LeastLoaded strategies both pick the shard with
+// the fewest assigned objects.
+//
+// Objects are not assigned to draining shards, or to shards in maintenance
+// unless their namespace is mapped to the shard.
+func PickShard(ctx context.Context, c client.Client, assignment *v1alpha1.Assignment, shards []v1alpha1.ShardStatus, obj client.Object) (string, error) {
+	names := []string{}
+	counts := map[string]int{}
+	for _, shard := range shards {
+		if shard.Phase == v1alpha1.ShardDrainingPhase {
+			continue
+		}
+		if shard.Phase == v1alpha1.ShardMaintenancePhase && assignment.Strategy != v1alpha1.NamespaceStrategy {
+			continue
+		}
+		names = append(names, shard.Name)
+		counts[shard.Name] = shard.AssignedObjects
+	}
+
+	if len(names) == 0 {
+		return "", nil
+	}
+
+	switch assignment.Strategy {
+	case "", v1alpha1.RoundRobinStrategy, v1alpha1.LeastLoadedStrategy:
+		return leastLoaded(names, counts)(obj), nil
+	case v1alpha1.ConsistentHashStrategy:
+		return rendezvous(names, obj), nil
+	case v1alpha1.NamespaceStrategy:
+		namespaceShards, err := mapNamespaces(ctx, c, assignment.Namespaces, counts)
+		if err != nil {
+			return "", err
+		}
+
+		return namespaceShards[obj.GetNamespace()], nil
+	}
+
+	return "", fmt.Errorf("unknown assignment strategy %q", assignment.Strategy)
+}
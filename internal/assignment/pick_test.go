@@ -0,0 +1,124 @@
+package assignment
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestMatches(t *testing.T) {
+	assignment := &templatesv1.Assignment{
+		Kinds: []string{"Kustomization"},
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"team": "a"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		kind   string
+		want   bool
+	}{
+		{name: "matching kind and labels", kind: "Kustomization", labels: map[string]string{"team": "a"}, want: true},
+		{name: "different kind", kind: "HelmRelease", labels: map[string]string{"team": "a"}, want: false},
+		{name: "different labels", kind: "Kustomization", labels: map[string]string{"team": "b"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := Matches(assignment, test.NewFluxObject(tt.kind, "default", "app", tt.labels))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matches != tt.want {
+				t.Fatalf("got %v, want %v", matches, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickShard(t *testing.T) {
+	shards := []templatesv1.ShardStatus{
+		{Name: "shard-a", AssignedObjects: 3},
+		{Name: "shard-b", AssignedObjects: 1},
+	}
+	cl := test.NewFakeFluxClient(test.NewNamespace("team-a", func(ns *corev1.Namespace) {
+		ns.Labels = map[string]string{"tier": "heavy"}
+	}))
+	obj := test.NewFluxObject("Kustomization", "team-a", "app", nil)
+
+	tests := []struct {
+		name       string
+		assignment templatesv1.Assignment
+		shards     []templatesv1.ShardStatus
+		want       string
+	}{
+		{
+			name:       "least loaded",
+			assignment: templatesv1.Assignment{Strategy: templatesv1.LeastLoadedStrategy},
+			shards:     shards,
+			want:       "shard-b",
+		},
+		{
+			name:       "round robin picks the least loaded shard",
+			assignment: templatesv1.Assignment{},
+			shards:     shards,
+			want:       "shard-b",
+		},
+		{
+			name:       "consistent hash",
+			assignment: templatesv1.Assignment{Strategy: templatesv1.ConsistentHashStrategy},
+			shards:     shards,
+			want:       rendezvous([]string{"shard-a", "shard-b"}, obj),
+		},
+		{
+			name: "namespace",
+			assignment: templatesv1.Assignment{
+				Strategy: templatesv1.NamespaceStrategy,
+				Namespaces: []templatesv1.NamespaceAssignment{
+					{
+						Shard: "shard-a",
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"tier": "heavy"},
+						},
+					},
+				},
+			},
+			shards: shards,
+			want:   "shard-a",
+		},
+		{
+			name:       "shards in maintenance or draining are not picked",
+			assignment: templatesv1.Assignment{Strategy: templatesv1.LeastLoadedStrategy},
+			shards: []templatesv1.ShardStatus{
+				{Name: "shard-a", AssignedObjects: 3},
+				{Name: "shard-b", Phase: templatesv1.ShardMaintenancePhase, AssignedObjects: 1},
+				{Name: "shard-c", Phase: templatesv1.ShardDrainingPhase},
+			},
+			want: "shard-a",
+		},
+		{
+			name:       "no shards",
+			assignment: templatesv1.Assignment{},
+			want:       "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shard, err := PickShard(context.TODO(), cl, &tt.assignment, tt.shards, obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if shard != tt.want {
+				t.Fatalf("got shard %q, want %q", shard, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+
+	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+)
+
+// RequestReconciliation requests the reconciliation of the Flux objects of the
+// kinds labelled with the shard key with requestedAt.
+//
+// Objects that already have the request, or have handled it, are not patched
+// again.
+func RequestReconciliation(ctx context.Context, c client.Client, kinds []string, shard, requestedAt string) error {
+	gks, err := parseKinds(kinds)
+	if err != nil {
+		return err
+	}
+
+	objects, err := fluxobjects.List(ctx, c, gks, client.MatchingLabels{deploys.ShardKeyLabel: shard})
+	if err != nil {
+		return err
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+		if obj.GetAnnotations()[fluxMeta.ReconcileRequestAnnotation] == requestedAt {
+			continue
+		}
+
+		u, err := getObject(ctx, c, obj)
+		if err != nil {
+			return err
+		}
+		if handled, _, _ := unstructured.NestedString(u.Object, "status", "lastHandledReconcileAt"); handled == requestedAt {
+			continue
+		}
+
+		patch := client.MergeFrom(obj.DeepCopy())
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[fluxMeta.ReconcileRequestAnnotation] = requestedAt
+		obj.SetAnnotations(annotations)
+
+		if err := c.Patch(ctx, obj, patch); err != nil {
+			return fmt.Errorf("failed to request reconciliation of %s %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, client.ObjectKeyFromObject(obj), err)
+		}
+	}
+
+	return nil
+}
+
+// Reconciled returns the number of Flux objects of the kinds labelled with the
+// shard key that have not handled the reconciliation requested at
+// requestedAt, and the number that handled the request and are not Ready.
+//
+// Suspended objects are not counted, they won't be reconciled until they are
+// resumed.
+func Reconciled(ctx context.Context, c client.Client, kinds []string, shard, requestedAt string) (int, int, error) {
+	gks, err := parseKinds(kinds)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	objects, err := fluxobjects.List(ctx, c, gks, client.MatchingLabels{deploys.ShardKeyLabel: shard})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pending, failed := 0, 0
+	for i := range objects {
+		u, err := getObject(ctx, c, &objects[i])
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if suspended, _, _ := unstructured.NestedBool(u.Object, "spec", "suspend"); suspended {
+			continue
+		}
+		if handled, _, _ := unstructured.NestedString(u.Object, "status", "lastHandledReconcileAt"); handled != requestedAt {
+			pending++
+			continue
+		}
+		if conditions.IsFalse(conditions.UnstructuredGetter(u), fluxMeta.ReadyCondition) {
+			failed++
+		}
+	}
+
+	return pending, failed, nil
+}
+
+// getObject returns the full object for a Flux object's metadata.
+func getObject(ctx context.Context, c client.Client, obj client.Object) (*unstructured.Unstructured, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), u); err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, client.ObjectKeyFromObject(obj), err)
+	}
+
+	return u, nil
+}
@@ -0,0 +1,79 @@
+package assignment
+
+import (
+	"context"
+	"testing"
+
+	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestRequestReconciliation(t *testing.T) {
+	handled := test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "shard-a"})
+	handled.SetAnnotations(map[string]string{fluxMeta.ReconcileRequestAnnotation: "other"})
+	test.AssertNoError(t, unstructured.SetNestedField(handled.Object, "rollout-1", "status", "lastHandledReconcileAt"))
+
+	objects := []client.Object{
+		handled,
+		test.NewFluxObject("Kustomization", "default", "app-2", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+		test.NewFluxObject("HelmRelease", "default", "app-3", map[string]string{deploys.ShardKeyLabel: "shard-b"}),
+		test.NewFluxObject("GitRepository", "default", "app-4", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+	}
+	cl := test.NewFakeFluxClient(objects...)
+
+	test.AssertNoError(t, RequestReconciliation(context.TODO(), cl, []string{"Kustomization", "HelmRelease"}, "shard-a", "rollout-1"))
+
+	want := map[string]string{
+		"app-1": "other",
+		"app-2": "rollout-1",
+		"app-3": "",
+		"app-4": "",
+	}
+	got := map[string]string{}
+	for _, obj := range objects {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+		test.AssertNoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(obj), u))
+		got[u.GetName()] = u.GetAnnotations()[fluxMeta.ReconcileRequestAnnotation]
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("failed to request reconciliation:\n%s", diff)
+	}
+}
+
+func TestReconciled(t *testing.T) {
+	newHandled := func(name, ready string) *unstructured.Unstructured {
+		obj := test.NewFluxObject("Kustomization", "default", name, map[string]string{deploys.ShardKeyLabel: "shard-a"})
+		test.AssertNoError(t, unstructured.SetNestedField(obj.Object, "rollout-1", "status", "lastHandledReconcileAt"))
+		test.AssertNoError(t, unstructured.SetNestedSlice(obj.Object, []interface{}{
+			map[string]interface{}{"type": fluxMeta.ReadyCondition, "status": ready, "reason": "Test", "lastTransitionTime": "2023-06-01T00:00:00Z"},
+		}, "status", "conditions"))
+		return obj
+	}
+
+	suspended := test.NewFluxObject("Kustomization", "default", "app-3", map[string]string{deploys.ShardKeyLabel: "shard-a"})
+	test.AssertNoError(t, unstructured.SetNestedField(suspended.Object, true, "spec", "suspend"))
+
+	cl := test.NewFakeFluxClient(
+		newHandled("app-1", "True"),
+		newHandled("app-2", "False"),
+		suspended,
+		test.NewFluxObject("HelmRelease", "default", "app-4", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+		test.NewFluxObject("HelmRelease", "default", "app-5", map[string]string{deploys.ShardKeyLabel: "shard-b"}),
+		test.NewFluxObject("GitRepository", "default", "app-6", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+	)
+
+	pending, failed, err := Reconciled(context.TODO(), cl, []string{"Kustomization", "HelmRelease"}, "shard-a", "rollout-1")
+	test.AssertNoError(t, err)
+	if pending != 1 {
+		t.Errorf("got %d pending objects, want 1", pending)
+	}
+	if failed != 1 {
+		t.Errorf("got %d failed objects, want 1", failed)
+	}
+}
@@ -0,0 +1,61 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+)
+
+// Unassign removes the shard key from the Flux objects of the kinds labelled
+// with the key of one of the shards, so that they are reconciled by the main
+// controller.
+//
+// The number of objects that were unassigned is returned.
+func Unassign(ctx context.Context, c client.Client, kinds []string, shards []string) (int, error) {
+	if len(shards) == 0 {
+		return 0, nil
+	}
+
+	gks, err := parseKinds(kinds)
+	if err != nil {
+		return 0, err
+	}
+
+	inShards, err := labels.NewRequirement(deploys.ShardKeyLabel, selection.In, shards)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create selector for shards: %w", err)
+	}
+
+	objects, err := fluxobjects.List(ctx, c, gks, client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*inShards)})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range objects {
+		if err := removeShardKey(ctx, c, &objects[i]); err != nil {
+			return i, err
+		}
+	}
+
+	return len(objects), nil
+}
+
+func removeShardKey(ctx context.Context, c client.Client, obj *metav1.PartialObjectMetadata) error {
+	patch := client.MergeFrom(obj.DeepCopy())
+	labels := obj.GetLabels()
+	delete(labels, deploys.ShardKeyLabel)
+	obj.SetLabels(labels)
+
+	if err := c.Patch(ctx, obj, patch); err != nil {
+		return fmt.Errorf("failed to unassign %s %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, client.ObjectKeyFromObject(obj), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package assignment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestUnassign(t *testing.T) {
+	objects := []client.Object{
+		test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+		test.NewFluxObject("HelmRelease", "default", "app-2", map[string]string{deploys.ShardKeyLabel: "shard-b", "team": "a"}),
+		test.NewFluxObject("HelmRelease", "default", "app-3", map[string]string{deploys.ShardKeyLabel: "other"}),
+		test.NewFluxObject("Kustomization", "default", "app-4", nil),
+		test.NewFluxObject("GitRepository", "default", "repo", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+	}
+	cl := test.NewFakeFluxClient(objects...)
+
+	unassigned, err := Unassign(context.TODO(), cl, []string{"Kustomization", "HelmRelease"}, []string{"shard-a", "shard-b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if unassigned != 2 {
+		t.Errorf("got %d unassigned objects, want 2", unassigned)
+	}
+
+	want := map[string]string{
+		"app-1": "",
+		"app-2": "",
+		"app-3": "other",
+		"app-4": "",
+		"repo":  "shard-a",
+	}
+	if diff := cmp.Diff(want, shardKeys(t, cl, objects)); diff != "" {
+		t.Errorf("failed to unassign objects:\n%s", diff)
+	}
+}
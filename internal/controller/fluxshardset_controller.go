@@ -18,60 +18,181 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	k8ssets "k8s.io/apimachinery/pkg/util/sets"
+	kuberecorder "k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
 	"sigs.k8s.io/cli-utils/pkg/object"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
+	helper "github.com/fluxcd/pkg/runtime/controller"
+	"github.com/fluxcd/pkg/runtime/patch"
 	"github.com/gitops-tools/pkg/sets"
 	"github.com/go-logr/logr"
 	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/assignment"
 	deploys "github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+	"github.com/weaveworks/flux-shard-controller/internal/generators"
+	"github.com/weaveworks/flux-shard-controller/internal/metrics"
+	"github.com/weaveworks/flux-shard-controller/internal/orphans"
 	appsv1 "k8s.io/api/apps/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
 )
 
 var accessor = meta.NewAccessor()
 
-const deploymentIndexKey string = ".metadata.reference.Deployment"
+// defaultDrainTimeout is how long removed shards are kept while they drain if
+// the drain timeout is not set.
+const defaultDrainTimeout = 10 * time.Minute
+
+// drainCheckInterval is how often FluxShardSets are reconciled while shards
+// are draining.
+const drainCheckInterval = 10 * time.Second
+
+// rolloutCheckInterval is how often FluxShardSets are reconciled while changes
+// are rolled out to the shards.
+const rolloutCheckInterval = 10 * time.Second
+
+// defaultInterval is how often FluxShardSets are reconciled if the interval
+// is not set.
+const defaultInterval = 5 * time.Minute
+
+// ownedConditions are the conditions that are owned by the controller when
+// patching the FluxShardSet.
+var ownedConditions = []string{
+	fluxMeta.ReadyCondition,
+	fluxMeta.ReconcilingCondition,
+	fluxMeta.StalledCondition,
+	templatesv1.OrphanedObjectsCondition,
+	templatesv1.DriftedCondition,
+	templatesv1.RolloutPausedCondition,
+}
+
+const (
+	deploymentIndexKey string = ".metadata.reference.Deployment"
+	configMapIndexKey  string = ".metadata.reference.ConfigMap"
+)
+
+// fieldManager is the field manager used when applying shard Deployments.
+const fieldManager = "flux-shard-controller"
+
+// legacyFieldManager is the field manager of shard Deployments created and
+// updated by earlier versions of the controller, the name of the controller
+// binary.
+const legacyFieldManager = "manager"
+
+// scaledFromReplicasAnnotation records the replicas of shard Deployments that
+// were scaled to zero because the source Deployment is missing, so that they
+// can be scaled back up when the source Deployment is recreated.
+const scaledFromReplicasAnnotation = "templates.weave.works/scaledFromReplicas"
+
+// Reasons for the events recorded for FluxShardSets.
+const (
+	deploymentCreatedReason    = "DeploymentCreated"
+	deploymentUpdatedReason    = "DeploymentUpdated"
+	deploymentDeletedReason    = "DeploymentDeleted"
+	deploymentRecreatingReason = "DeploymentRecreating"
+	deploymentRecreatedReason  = "DeploymentRecreated"
+	objectsReassignedReason    = "ObjectsReassigned"
+	shardDrainingReason        = "ShardDraining"
+	shardDrainedReason         = "ShardDrained"
+	drainTimeoutReason         = "DrainTimeout"
+	rolloutFailedReason        = "RolloutFailed"
+	rolloutCompletedReason     = "RolloutCompleted"
+	shardRestartedReason       = "ShardRestarted"
+	driftCorrectedReason       = "DriftCorrected"
+)
 
 // FluxShardSetReconciler reconciles a FluxShardSet object
 type FluxShardSetReconciler struct {
 	client.Client
+	kuberecorder.EventRecorder
+	helper.Metrics
 	Scheme *runtime.Scheme
 }
 
 // +kubebuilder:rbac:groups=templates.weave.works,resources=fluxshardsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=templates.weave.works,resources=fluxshardsets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=templates.weave.works,resources=fluxshardsets/finalizers,verbs=update
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=helm.toolkit.fluxcd.io,resources=helmreleases,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=gitrepositories;ocirepositories;helmrepositories;helmcharts;buckets,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=notification.toolkit.fluxcd.io,resources=alerts;providers;receivers,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=image.toolkit.fluxcd.io,resources=imagerepositories;imagepolicies;imageupdateautomations,verbs=get;list;watch;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
-func (r *FluxShardSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *FluxShardSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, retErr error) {
 	logger := log.FromContext(ctx)
+	start := time.Now()
 
 	shardSet := templatesv1.FluxShardSet{}
 	if err := r.Client.Get(ctx, req.NamespacedName, &shardSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			metrics.Delete(req.Namespace, req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Clean up the shards before the FluxShardSet is removed.
+	if !shardSet.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.reconcileDelete(ctx, &shardSet)
+	}
+
+	r.RecordSuspend(ctx, &shardSet, shardSet.Spec.Suspend)
+
 	// Skip reconciliation if the FluxShardSet is suspended.
 	if shardSet.Spec.Suspend {
 		logger.Info("Reconciliation is suspended for this FluxShardSet")
 		return ctrl.Result{}, nil
 	}
 
+	patcher := patch.NewSerialPatcher(&shardSet, r.Client)
+
+	// Always attempt to patch the status and conditions after each
+	// reconciliation.
+	defer func() {
+		if err := patcher.Patch(ctx, &shardSet, patch.WithOwnedConditions{Conditions: ownedConditions}); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "failed to reconcile")
+			retErr = kerrors.NewAggregate([]error{retErr, fmt.Errorf("failed to update status and inventory: %w", err)})
+		}
+
+		r.recordMetrics(ctx, &shardSet, start)
+	}()
+
+	// The finalizer is added by the patch after reconciling.
+	controllerutil.AddFinalizer(&shardSet, templatesv1.FluxShardSetFinalizer)
+
 	// Set the value of the reconciliation request in status.
 	if v, ok := fluxMeta.ReconcileAnnotationValue(shardSet.GetAnnotations()); ok {
 		shardSet.Status.LastHandledReconcileAt = v
@@ -79,29 +200,276 @@ func (r *FluxShardSetReconciler) Reconcile(ctx context.Context, req ctrl.Request
 
 	inventory, err := r.reconcileResources(ctx, &shardSet)
 	if err != nil {
-		templatesv1.SetFluxShardSetReadiness(&shardSet, metav1.ConditionFalse, templatesv1.ReconciliationFailedReason, err.Error())
-		if err := r.patchStatus(ctx, req, shardSet.Status); err != nil {
-			logger.Error(err, "failed to reconcile")
+		// Stalling errors won't be resolved by retrying, the FluxShardSet is
+		// reconciled again when it or the source Deployment changes.
+		var stalling *stallingError
+		if errors.As(err, &stalling) {
+			templatesv1.SetStalled(&shardSet, stalling.reason, err.Error())
+			r.Event(&shardSet, corev1.EventTypeWarning, stalling.reason, err.Error())
+			logger.Error(err, "reconciliation stalled")
+			return ctrl.Result{}, nil
 		}
 
-		return ctrl.Result{}, err
+		return r.reconciliationFailed(ctx, &shardSet, err)
 	}
 
 	if inventory != nil {
-		templatesv1.SetReadyWithInventory(&shardSet, inventory, templatesv1.ReconciliationSucceededReason,
-			fmt.Sprintf("%d shard(s) created", len(inventory.Entries)))
+		if err := r.reconcileOrphans(ctx, &shardSet); err != nil {
+			return r.reconciliationFailed(ctx, &shardSet, err)
+		}
 
-		if err := r.patchStatus(ctx, req, shardSet.Status); client.IgnoreNotFound(err) != nil {
-			templatesv1.SetFluxShardSetReadiness(&shardSet, metav1.ConditionFalse, templatesv1.ReconciliationFailedReason, err.Error())
-			logger.Error(err, "failed to reconcile")
-			return ctrl.Result{}, fmt.Errorf("failed to update status and inventory: %w", err)
+		if draining := drainingShards(shardSet.Status.Shards); draining > 0 {
+			templatesv1.SetProgressingWithInventory(&shardSet, inventory,
+				fmt.Sprintf("%d shard(s) created, %d draining", len(inventory.Entries)-draining, draining))
+		} else if recreating := recreatingShards(shardSet.Status.Shards); recreating > 0 {
+			templatesv1.SetProgressingWithInventory(&shardSet, inventory,
+				fmt.Sprintf("%d shard(s) created, %d recreating", len(inventory.Entries), recreating))
+		} else if rolling := rollingOutShards(shardSet.Status.Rollout); rolling > 0 {
+			message := fmt.Sprintf("%d shard(s) created, %d rolling out", len(inventory.Entries), rolling)
+			if conditions.IsTrue(&shardSet, templatesv1.RolloutPausedCondition) {
+				message += ", rollout paused"
+			}
+			templatesv1.SetProgressingWithInventory(&shardSet, inventory, message)
+		} else if unavailable := unavailableShards(shardSet.Status.Shards); unavailable > 0 {
+			templatesv1.SetProgressingWithInventory(&shardSet, inventory,
+				fmt.Sprintf("%d shard(s) created, %d not yet available", len(inventory.Entries), unavailable))
+		} else {
+			templatesv1.SetReadyWithInventory(&shardSet, inventory, templatesv1.ReconciliationSucceededReason,
+				fmt.Sprintf("%d shard(s) created", len(inventory.Entries)))
+		}
+	}
+
+	// Requeue at the interval to check for drift and orphaned objects, or
+	// sooner to remove discovered shards when their grace period elapses, to
+	// check draining shards and to continue rollouts.
+	requeueAfter := reconcileInterval(&shardSet)
+	if d := generators.DiscoveryRequeueAfter(&shardSet); d > 0 && d < requeueAfter {
+		requeueAfter = d
+	}
+	if drainingShards(shardSet.Status.Shards) > 0 && drainCheckInterval < requeueAfter {
+		requeueAfter = drainCheckInterval
+	}
+	if rollingOutShards(shardSet.Status.Rollout) > 0 && rolloutCheckInterval < requeueAfter {
+		requeueAfter = rolloutCheckInterval
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcileDelete cleans up the shards of a FluxShardSet that is being deleted
+// according to its deletion policy, and removes the finalizer.
+func (r *FluxShardSetReconciler) reconcileDelete(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet) error {
+	if !controllerutil.ContainsFinalizer(fluxShardSet, templatesv1.FluxShardSetFinalizer) {
+		return nil
+	}
+
+	var refs []templatesv1.ResourceRef
+	if fluxShardSet.Status.Inventory != nil {
+		refs = fluxShardSet.Status.Inventory.Entries
+	}
+
+	switch fluxShardSet.Spec.DeletionPolicy {
+	case templatesv1.OrphanDeletionPolicy:
+		if err := r.orphanResourceRefs(ctx, fluxShardSet, refs); err != nil {
+			return err
+		}
+	case templatesv1.ReassignToMainDeletionPolicy:
+		if err := r.reassignToMain(ctx, fluxShardSet); err != nil {
+			return err
+		}
+		if err := r.removeResourceRefs(ctx, fluxShardSet, refs); err != nil {
+			return err
+		}
+	default:
+		if err := r.removeResourceRefs(ctx, fluxShardSet, refs); err != nil {
+			return err
 		}
 	}
 
-	return ctrl.Result{}, nil
+	patch := client.MergeFrom(fluxShardSet.DeepCopy())
+	controllerutil.RemoveFinalizer(fluxShardSet, templatesv1.FluxShardSetFinalizer)
+	if err := r.Client.Patch(ctx, fluxShardSet, patch); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to remove finalizer: %w", err)
+	}
+	metrics.Delete(fluxShardSet.GetNamespace(), fluxShardSet.GetName())
+
+	return nil
 }
 
-func (r *FluxShardSetReconciler) removeResourceRefs(ctx context.Context, deletions []templatesv1.ResourceRef) error {
+// orphanResourceRefs removes the owner reference to the FluxShardSet from the
+// Deployments so that they are not garbage collected.
+func (r *FluxShardSetReconciler) orphanResourceRefs(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, refs []templatesv1.ResourceRef) error {
+	for _, ref := range refs {
+		d, err := deploymentFromResourceRef(ref)
+		if err != nil {
+			return err
+		}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(d), d); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to load Deployment to orphan: %w", err)
+		}
+
+		patch := client.MergeFrom(d.DeepCopy())
+		owners := []metav1.OwnerReference{}
+		for _, owner := range d.GetOwnerReferences() {
+			if owner.UID != fluxShardSet.GetUID() {
+				owners = append(owners, owner)
+			}
+		}
+		d.SetOwnerReferences(owners)
+		if err := r.Client.Patch(ctx, d, patch, client.FieldOwner(fieldManager)); err != nil {
+			return fmt.Errorf("failed to orphan Deployment %s: %w", client.ObjectKeyFromObject(d), err)
+		}
+	}
+
+	return nil
+}
+
+// reassignToMain removes the shard key from the Flux objects assigned to the
+// shards of the FluxShardSet, unless the shard is also served by another
+// FluxShardSet.
+func (r *FluxShardSetReconciler) reassignToMain(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet) error {
+	served, err := r.servedByOthers(ctx, fluxShardSet)
+	if err != nil {
+		return err
+	}
+
+	shards := []string{}
+	for _, shard := range fluxShardSet.Status.Shards {
+		if !served[shard.Name] {
+			shards = append(shards, shard.Name)
+		}
+	}
+
+	kinds, err := assignment.Kinds(fluxShardSet)
+	if err != nil {
+		return fmt.Errorf("failed to reassign objects to the main controller: %w", err)
+	}
+
+	unassigned, err := assignment.Unassign(ctx, r.Client, kinds, shards)
+	if err != nil {
+		return fmt.Errorf("failed to reassign objects to the main controller: %w", err)
+	}
+	if unassigned > 0 {
+		r.Eventf(fluxShardSet, corev1.EventTypeNormal, objectsReassignedReason,
+			"%d object(s) reassigned to the main controller", unassigned)
+	}
+
+	return nil
+}
+
+// servedByOthers returns the shards that are served by FluxShardSets other
+// than the fluxShardSet.
+func (r *FluxShardSetReconciler) servedByOthers(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet) (map[string]bool, error) {
+	var list templatesv1.FluxShardSetList
+	if err := r.Client.List(ctx, &list); err != nil {
+		return nil, fmt.Errorf("failed to list FluxShardSets: %w", err)
+	}
+
+	others := []templatesv1.FluxShardSet{}
+	for _, set := range list.Items {
+		if client.ObjectKeyFromObject(&set) != client.ObjectKeyFromObject(fluxShardSet) {
+			others = append(others, set)
+		}
+	}
+
+	return orphans.ServedShards(others), nil
+}
+
+// reconciliationFailed records the failed reconciliation in the status of the
+// FluxShardSet.
+//
+// If the FluxShardSet has a retry interval it is requeued after the retry
+// interval, otherwise the error is returned to retry with a backoff.
+func (r *FluxShardSetReconciler) reconciliationFailed(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, err error) (ctrl.Result, error) {
+	templatesv1.SetReconciliationFailed(fluxShardSet, err.Error())
+	r.Event(fluxShardSet, corev1.EventTypeWarning, templatesv1.ReconciliationFailedReason, err.Error())
+
+	if fluxShardSet.Spec.RetryInterval != nil {
+		log.FromContext(ctx).Error(err, "reconciliation failed", "retryInterval", fluxShardSet.Spec.RetryInterval.Duration)
+		return ctrl.Result{RequeueAfter: fluxShardSet.Spec.RetryInterval.Duration}, nil
+	}
+
+	return ctrl.Result{}, err
+}
+
+// reconcileInterval returns how often the FluxShardSet is reconciled.
+func reconcileInterval(fluxShardSet *templatesv1.FluxShardSet) time.Duration {
+	if fluxShardSet.Spec.Interval != nil {
+		return fluxShardSet.Spec.Interval.Duration
+	}
+
+	return defaultInterval
+}
+
+// reconcileOrphans finds the Flux objects with shard keys that are not served
+// by any FluxShardSet and records them in the status.
+func (r *FluxShardSetReconciler) reconcileOrphans(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet) error {
+	var list templatesv1.FluxShardSetList
+	if err := r.Client.List(ctx, &list); err != nil {
+		return fmt.Errorf("failed to list FluxShardSets: %w", err)
+	}
+
+	// The shards in the status of this FluxShardSet were updated by this
+	// reconciliation.
+	sets := []templatesv1.FluxShardSet{*fluxShardSet}
+	for _, set := range list.Items {
+		if client.ObjectKeyFromObject(&set) != client.ObjectKeyFromObject(fluxShardSet) {
+			sets = append(sets, set)
+		}
+	}
+
+	var kinds []string
+	if fluxShardSet.Spec.Assignment != nil {
+		kinds = fluxShardSet.Spec.Assignment.Kinds
+	}
+
+	orphaned, err := orphans.Find(ctx, r.Client, kinds, orphans.ServedShards(sets))
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned objects: %w", err)
+	}
+	templatesv1.SetOrphanedObjects(fluxShardSet, orphaned)
+	metrics.RecordOrphanedObjects(fluxShardSet, len(orphaned))
+
+	return nil
+}
+
+// recordMetrics records the Flux metrics for the conditions and duration of the
+// reconciliation, and the metrics for the shards and the objects assigned to
+// them.
+func (r *FluxShardSetReconciler) recordMetrics(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, start time.Time) {
+	r.RecordReadiness(ctx, fluxShardSet)
+	r.RecordReconciling(ctx, fluxShardSet)
+	r.RecordStalled(ctx, fluxShardSet)
+	r.RecordDuration(ctx, fluxShardSet, start)
+
+	metrics.RecordReconcile(fluxShardSet, conditions.GetReason(fluxShardSet, fluxMeta.ReadyCondition))
+	metrics.RecordShards(fluxShardSet)
+
+	names := []string{}
+	for _, shard := range fluxShardSet.Status.Shards {
+		names = append(names, shard.Name)
+	}
+	kinds, err := assignment.Kinds(fluxShardSet)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to count assigned objects")
+		return
+	}
+	counted := &templatesv1.Assignment{Kinds: kinds}
+	if fluxShardSet.Spec.Assignment != nil {
+		counted.Selector = fluxShardSet.Spec.Assignment.Selector
+	}
+	assigned, unassigned, err := assignment.Count(ctx, r.Client, counted, names)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to count assigned objects")
+		return
+	}
+	metrics.RecordObjects(fluxShardSet, assigned, unassigned)
+}
+
+func (r *FluxShardSetReconciler) removeResourceRefs(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, deletions []templatesv1.ResourceRef) error {
 	logger := log.FromContext(ctx)
 	for _, v := range deletions {
 		d, err := deploymentFromResourceRef(v)
@@ -111,9 +479,20 @@ func (r *FluxShardSetReconciler) removeResourceRefs(ctx context.Context, deletio
 		if err := logResourceMessage(logger, "deleting resource", d); err != nil {
 			return err
 		}
+		// The temporary Deployment is left over if the Deployment was
+		// removed while it was recreated.
+		temporary := temporaryDeployment(d)
+		if err := r.Client.Delete(ctx, temporary); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete %v: %w", temporary, err)
+		}
 		if err := r.Client.Delete(ctx, d); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
 			return fmt.Errorf("failed to delete %v: %w", d, err)
 		}
+		r.Eventf(fluxShardSet, corev1.EventTypeNormal, deploymentDeletedReason,
+			"Deployment %s deleted", client.ObjectKeyFromObject(d))
 	}
 
 	return nil
@@ -126,13 +505,44 @@ func (r *FluxShardSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return fmt.Errorf("failed setting index fields: %w", err)
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := mgr.GetCache().IndexField(
+		context.TODO(), &templatesv1.FluxShardSet{}, configMapIndexKey, indexConfigMaps); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
+
+	// Only the Flux kinds installed when the controller starts are watched for
+	// discovery and assignment.
+	fluxKinds, err := fluxobjects.InstalledKinds(mgr.GetRESTMapper(), fluxobjects.Kinds)
+	if err != nil {
+		return fmt.Errorf("failed to find installed Flux kinds: %w", err)
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&templatesv1.FluxShardSet{}).
+		Owns(&appsv1.Deployment{}).
 		Watches(
 			&appsv1.Deployment{},
 			handler.EnqueueRequestsFromMapFunc(r.deploymentsToFluxShardSet),
 		).
-		Complete(r)
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.configMapsToFluxShardSet),
+		).
+		Watches(
+			&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.namespacesToFluxShardSet),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}),
+		)
+
+	for _, gvk := range fluxKinds {
+		b = b.WatchesMetadata(
+			fluxobjects.NewObject(gvk),
+			handler.EnqueueRequestsFromMapFunc(r.fluxObjectsToFluxShardSet),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}),
+		)
+	}
+
+	return b.Complete(r)
 }
 
 func (r *FluxShardSetReconciler) reconcileResources(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet) (*templatesv1.ResourceInventory, error) {
@@ -140,73 +550,345 @@ func (r *FluxShardSetReconciler) reconcileResources(ctx context.Context, fluxSha
 
 	srcDeploy, err := r.getSourceDeployment(ctx, fluxShardSet)
 	if err != nil {
-		return nil, client.IgnoreNotFound(err)
+		if apierrors.IsNotFound(err) {
+			return nil, r.reconcileMissingSource(ctx, fluxShardSet)
+		}
+
+		return nil, fmt.Errorf("failed to load source Deployment: %w", err)
+	}
+
+	shards, err := generators.ExpandShards(ctx, r.Client, fluxShardSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate shards: %w", err)
 	}
 
-	generatedDeployments, err := deploys.GenerateDeployments(fluxShardSet, srcDeploy)
+	generatedDeployments, err := deploys.GenerateDeployments(fluxShardSet, srcDeploy, shards)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate deployments: %w", err)
+		return nil, &stallingError{
+			reason: templatesv1.DeploymentGenerationFailedReason,
+			err:    fmt.Errorf("failed to generate deployments: %w", err),
+		}
 	}
 
-	existingInventory := sets.New[templatesv1.ResourceRef]()
+	// existingInventory holds the resource refs from the last reconciliation
+	// by ID.
+	existingInventory := map[string]templatesv1.ResourceRef{}
 	if fluxShardSet.Status.Inventory != nil {
-		existingInventory.Insert(fluxShardSet.Status.Inventory.Entries...)
+		for _, ref := range fluxShardSet.Status.Inventory.Entries {
+			existingInventory[ref.ID] = ref
+		}
 	}
 
 	// newInventory holds the resource refs for the generated resources.
 	newInventory := sets.New[templatesv1.ResourceRef]()
 
-	for _, newDeployment := range generatedDeployments {
+	// applied holds the Deployments returned by the API server for each shard.
+	applied := make([]*appsv1.Deployment, len(generatedDeployments))
+
+	// drifted describes the Deployments that have drifted from the generated
+	// Deployments and were not corrected.
+	drifted := []string{}
+
+	// recreated holds the names of the shards with Deployments that were
+	// recreated to change immutable fields, and recreating holds the phase of
+	// the shards with Deployments that are being recreated.
+	recreated := sets.New[string]()
+	recreating := map[string]templatesv1.RecreatePhase{}
+
+	previousStatuses := map[string]templatesv1.ShardStatus{}
+	deploymentShards := map[client.ObjectKey]templatesv1.ShardStatus{}
+	for _, shard := range fluxShardSet.Status.Shards {
+		previousStatuses[shard.Name] = shard
+		if shard.DeploymentRef != nil {
+			deploymentShards[client.ObjectKey{Namespace: shard.DeploymentRef.Namespace, Name: shard.DeploymentRef.Name}] = shard
+		}
+	}
+
+	// refs holds the resource refs of the generated Deployments, and current
+	// holds the existing Deployment for each shard, or nil if the Deployment
+	// doesn't exist.
+	refs := make([]templatesv1.ResourceRef, len(generatedDeployments))
+	current := make([]*appsv1.Deployment, len(generatedDeployments))
+	for i, newDeployment := range generatedDeployments {
 		ref, err := templatesv1.ResourceRefFromObject(newDeployment)
 		if err != nil {
 			return nil, fmt.Errorf("failed to update inventory: %w", err)
 		}
+		ref.Checksum, err = deploys.SetChecksum(newDeployment)
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = ref
+
+		existing := &appsv1.Deployment{}
+		err = r.Client.Get(ctx, client.ObjectKeyFromObject(newDeployment), existing)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to load existing Deployment: %w", err)
+		}
+		if err == nil {
+			current[i] = existing
+		}
+
+		// Deployments owned by this FluxShardSet that are not in the
+		// inventory are adopted, these were applied by a reconciliation that
+		// failed before the inventory was updated.
+		if _, inInventory := existingInventory[ref.ID]; err == nil && !inInventory && isOwnedBy(existing, fluxShardSet) {
+			existingInventory[ref.ID] = templatesv1.ResourceRef{ID: ref.ID, Version: ref.Version}
+		}
+
+		if _, inInventory := existingInventory[ref.ID]; err == nil && inInventory {
+			if err := r.upgradeManagedFields(ctx, existing); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// held holds the names of the shards with Deployments that are not
+	// updated yet by the rollout strategy.
+	held, err := r.reconcileRollout(ctx, fluxShardSet, shards, refs, current, existingInventory)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, newDeployment := range generatedDeployments {
+		ref := refs[i]
+		previous, inInventory := existingInventory[ref.ID]
+		existing := current[i]
+		exists := existing != nil
+
+		// The Deployments of suspended shards are left unchanged, and are
+		// kept in the inventory even if they have been deleted so they are
+		// not drained.
+		if shards[i].Suspend {
+			if inInventory {
+				newInventory.Insert(previous)
+				applied[i] = existing
+			}
+			recreating[shards[i].Name] = previousStatuses[shards[i].Name].RecreatePhase
+			continue
+		}
 
-		if existingInventory.Has(ref) {
+		// Deployments that are being recreated are replaced step by step,
+		// the FluxShardSet is reconciled again when the Deployments change.
+		if phase := previousStatuses[shards[i].Name].RecreatePhase; phase != "" && inInventory {
+			phase, err := r.recreateDeployment(ctx, fluxShardSet, existing, newDeployment, phase)
+			if err != nil {
+				return nil, err
+			}
+			if phase == "" {
+				recreated.Insert(shards[i].Name)
+			}
+			recreating[shards[i].Name] = phase
 			newInventory.Insert(ref)
-			existing := &appsv1.Deployment{}
-			err = r.Client.Get(ctx, client.ObjectKeyFromObject(newDeployment), existing)
-			if err == nil {
-				newDeployment = copyDeploymentContent(existing, newDeployment)
-				if err := r.Client.Patch(ctx, newDeployment, client.MergeFrom(existing)); err != nil {
-					return nil, fmt.Errorf("failed to update Deployment: %w", err)
-				}
+			applied[i] = existing
+			continue
+		}
+
+		// Deployments scaled to zero while the source Deployment was missing
+		// are scaled back up.
+		if exists && inInventory {
+			if err := r.restoreScaledReplicas(ctx, fluxShardSet, existing); err != nil {
+				return nil, err
+			}
+		}
+
+		// Deployments that were not created by this FluxShardSet are not
+		// taken over.
+		if exists && !inInventory {
+			return nil, fmt.Errorf("failed to create Deployment: %w",
+				apierrors.NewAlreadyExists(appsv1.Resource("deployments"), newDeployment.GetName()))
+		}
+
+		// Changes held back by the rollout strategy are applied when the
+		// shards updated before have rolled out.
+		if held.Has(shards[i].Name) {
+			newInventory.Insert(previous)
+			applied[i] = existing
+			continue
+		}
 
-				if err := logResourceMessage(logger, "updated deployment", newDeployment); err != nil {
-					return nil, err
+		// The Deployment isn't applied if the generated Deployment hasn't
+		// changed since it was last applied, unless the Deployment has been
+		// changed since and the drift is to be corrected.
+		if exists && previous.Checksum == ref.Checksum && existing.GetAnnotations()[deploys.ChecksumAnnotation] == ref.Checksum {
+			drift, err := deploys.Drift(newDeployment, existing, fieldManager)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(drift) == 0 || fluxShardSet.Spec.DriftPolicy == templatesv1.ReportDriftPolicy {
+				if len(drift) > 0 {
+					drifted = append(drifted, fmt.Sprintf("%s (%s)", client.ObjectKeyFromObject(existing), strings.Join(drift, ", ")))
 				}
+				newInventory.Insert(ref)
+				applied[i] = existing
+				metrics.RecordDeploymentWrite(fluxShardSet, metrics.SkippedWrite)
 				continue
 			}
 
-			if !apierrors.IsNotFound(err) {
-				return nil, fmt.Errorf("failed to load existing Deployment: %w", err)
+			// Applying takes back the ownership of the drifted fields from
+			// the field managers that changed them e.g. kubectl edit, so the
+			// drift is corrected without conflicts.
+			r.Eventf(fluxShardSet, corev1.EventTypeNormal, driftCorrectedReason,
+				"Deployment %s drift corrected: %s", client.ObjectKeyFromObject(existing), strings.Join(drift, ", "))
+		}
+
+		if err := r.applyDeployment(ctx, fluxShardSet, newDeployment); err != nil {
+			if !exists || !isImmutableFieldError(err) {
+				return nil, err
 			}
 
+			// Immutable fields can't be changed by applying, the Deployment
+			// is replaced with a Deployment with the new fields.
+			phase, err := r.recreateDeployment(ctx, fluxShardSet, existing, newDeployment, "")
+			if err != nil {
+				return nil, err
+			}
+			recreating[shards[i].Name] = phase
+			newInventory.Insert(ref)
+			applied[i] = existing
+			continue
 		}
+		newInventory.Insert(ref)
+		applied[i] = newDeployment
+		metrics.RecordDeploymentWrite(fluxShardSet, metrics.AppliedWrite)
 
-		if err := controllerutil.SetOwnerReference(fluxShardSet, newDeployment, r.Scheme); err != nil {
-			return nil, fmt.Errorf("failed to set owner reference: %w", err)
+		if !exists {
+			if err := logResourceMessage(logger, "created new deployment", newDeployment); err != nil {
+				return nil, err
+			}
+			r.Eventf(fluxShardSet, corev1.EventTypeNormal, deploymentCreatedReason,
+				"Deployment %s created", client.ObjectKeyFromObject(newDeployment))
+			continue
 		}
 
-		if err := r.Client.Create(ctx, newDeployment); err != nil {
-			return nil, fmt.Errorf("failed to create Deployment: %w", err)
+		if err := logResourceMessage(logger, "updated deployment", newDeployment); err != nil {
+			return nil, err
 		}
-		newInventory.Insert(ref)
-		if err := logResourceMessage(logger, "created new deployment", newDeployment); err != nil {
+		// The resource version only changes if applying changed the
+		// Deployment.
+		if newDeployment.GetResourceVersion() != existing.GetResourceVersion() {
+			r.Eventf(fluxShardSet, corev1.EventTypeNormal, deploymentUpdatedReason,
+				"Deployment %s updated", client.ObjectKeyFromObject(newDeployment))
+		}
+	}
+
+	// Deployments that are no longer generated are deleted when the objects
+	// assigned to their shard have been moved and reconciled by their new
+	// controller. This happens before assignment so that the objects are moved
+	// with a reconciliation request.
+	active := []templatesv1.ShardStatus{}
+	for _, shard := range shards {
+		active = append(active, templatesv1.ShardStatus{Name: shard.Name, Phase: shardPhase(shard), AssignedObjects: previousStatuses[shard.Name].AssignedObjects})
+	}
+	generated := sets.New[string]()
+	for _, ref := range newInventory.List() {
+		generated.Insert(ref.ID)
+	}
+	suspended := sets.New[string]()
+	for _, shard := range shards {
+		if shard.Suspend {
+			suspended.Insert(shard.Name)
+		}
+	}
+	served, err := r.servedByOthers(ctx, fluxShardSet)
+	if err != nil {
+		return nil, err
+	}
+	drainingStatuses := []templatesv1.ShardStatus{}
+	objectsToRemove := []templatesv1.ResourceRef{}
+	for id, ref := range existingInventory {
+		if generated.Has(id) {
+			continue
+		}
+		d, err := deploymentFromResourceRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		shard, ok := deploymentShards[client.ObjectKeyFromObject(d)]
+		if !ok {
+			objectsToRemove = append(objectsToRemove, ref)
+			continue
+		}
+
+		// Suspended shards are never drained, Deployments that are no longer
+		// generated for them are kept until the shard is resumed.
+		if suspended.Has(shard.Name) {
+			newInventory.Insert(ref)
+			continue
+		}
+
+		// The objects of shards that are also served by other FluxShardSets
+		// are left to the other FluxShardSets.
+		if served[shard.Name] {
+			objectsToRemove = append(objectsToRemove, ref)
+			continue
+		}
+
+		drainingStatus, drained, err := r.drainShard(ctx, fluxShardSet, shard, active)
+		if err != nil {
 			return nil, err
 		}
+		if drained {
+			objectsToRemove = append(objectsToRemove, ref)
+			continue
+		}
+		newInventory.Insert(ref)
+		drainingStatuses = append(drainingStatuses, drainingStatus)
 	}
+	sort.Slice(drainingStatuses, func(i, j int) bool {
+		return drainingStatuses[i].Name < drainingStatuses[j].Name
+	})
 
-	if fluxShardSet.Status.Inventory == nil {
-		return &templatesv1.ResourceInventory{Entries: newInventory.SortedList(func(x, y templatesv1.ResourceRef) bool {
-			return x.ID < y.ID
-		})}, nil
+	assigned, err := r.reconcileAssignment(ctx, fluxShardSet, shards)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := r.countMaintenanceObjects(ctx, fluxShardSet, shards)
+	if err != nil {
+		return nil, err
+	}
+
+	shardStatuses := []templatesv1.ShardStatus{}
+	for i, shard := range shards {
+		shardStatus, err := newShardStatus(shard.Name, assigned[shard.Name], applied[i])
+		if err != nil {
+			return nil, err
+		}
+		shardStatus.Phase = shardPhase(shard)
+		// Suspended shards keep the reference to their Deployment if it
+		// has been deleted, so they are not drained when they are removed.
+		if shard.Suspend && shardStatus.DeploymentRef == nil {
+			shardStatus.DeploymentRef = previousStatuses[shard.Name].DeploymentRef
+		}
+		shardStatus.AffectedObjects = affected[shard.Name]
+		shardStatus.RecreatePhase = recreating[shard.Name]
+		shardStatus.RecreatedAt = previousStatuses[shard.Name].RecreatedAt
+		if recreated.Has(shard.Name) {
+			now := metav1.Now()
+			shardStatus.RecreatedAt = &now
+		}
+		// Restarts are not reported for the Deployments of new shards.
+		if previous, ok := previousStatuses[shard.Name]; ok && previous.DeploymentRef != nil && shardStatus.RestartedAt != "" && previous.RestartedAt != shardStatus.RestartedAt {
+			r.Eventf(fluxShardSet, corev1.EventTypeNormal, shardRestartedReason,
+				"Deployment %s for shard %s restarted at %s", client.ObjectKeyFromObject(applied[i]), shard.Name, shardStatus.RestartedAt)
+		}
+		if shardStatus.Health == status.FailedStatus.String() && previousStatuses[shard.Name].Health != shardStatus.Health {
+			r.Eventf(fluxShardSet, corev1.EventTypeWarning, rolloutFailedReason,
+				"Deployment %s for shard %s failed to roll out", client.ObjectKeyFromObject(applied[i]), shard.Name)
+		}
+		shardStatuses = append(shardStatuses, shardStatus)
+	}
+	fluxShardSet.Status.Shards = append(shardStatuses, drainingStatuses...)
 
+	if len(drifted) > 0 && !conditions.IsTrue(fluxShardSet, templatesv1.DriftedCondition) {
+		r.Eventf(fluxShardSet, corev1.EventTypeWarning, templatesv1.DriftDetectedReason,
+			"%d Deployment(s) have drifted: %s", len(drifted), strings.Join(drifted, "; "))
 	}
+	templatesv1.SetDrifted(fluxShardSet, drifted)
 
-	// if existingEntries has more Deployments not in generated Deployments, delete and remove them from inventory
-	objectsToRemove := existingInventory.Difference(newInventory)
-	if err := r.removeResourceRefs(ctx, objectsToRemove.List()); err != nil {
+	if err := r.removeResourceRefs(ctx, fluxShardSet, objectsToRemove); err != nil {
 		return nil, err
 	}
 
@@ -215,6 +897,327 @@ func (r *FluxShardSetReconciler) reconcileResources(ctx context.Context, fluxSha
 	})}, nil
 }
 
+// countMaintenanceObjects returns the number of Flux objects labelled for
+// each of the shards in maintenance.
+func (r *FluxShardSetReconciler) countMaintenanceObjects(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, shards []templatesv1.ShardSpec) (map[string]int, error) {
+	names := []string{}
+	for _, shard := range shards {
+		if shard.Maintenance {
+			names = append(names, shard.Name)
+		}
+	}
+	if len(names) == 0 {
+		return map[string]int{}, nil
+	}
+
+	kinds, err := assignment.Kinds(fluxShardSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count objects for shards in maintenance: %w", err)
+	}
+
+	counts, _, err := assignment.Count(ctx, r.Client, &templatesv1.Assignment{Kinds: kinds}, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count objects for shards in maintenance: %w", err)
+	}
+
+	affected := map[string]int{}
+	for shard, kinds := range counts {
+		for _, count := range kinds {
+			affected[shard] += count
+		}
+	}
+
+	return affected, nil
+}
+
+// shardPhase returns the phase of a shard in the FluxShardSet.
+func shardPhase(shard templatesv1.ShardSpec) templatesv1.ShardPhase {
+	switch {
+	case shard.Suspend:
+		return templatesv1.ShardSuspendedPhase
+	case shard.Maintenance:
+		return templatesv1.ShardMaintenancePhase
+	}
+
+	return templatesv1.ShardActivePhase
+}
+
+// drainShard moves the Flux objects assigned to a shard that has been removed
+// to the active shards, or to the main controller, and returns the status of
+// the draining shard.
+//
+// The shard has drained when all the moved objects have been reconciled by
+// their new controller, or the drain timeout has elapsed, and its Deployment
+// can be deleted.
+func (r *FluxShardSetReconciler) drainShard(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, shard templatesv1.ShardStatus, active []templatesv1.ShardStatus) (templatesv1.ShardStatus, bool, error) {
+	kinds, err := assignment.Kinds(fluxShardSet)
+	if err != nil {
+		return shard, false, fmt.Errorf("failed to drain shard %s: %w", shard.Name, err)
+	}
+
+	wasDraining := shard.Phase == templatesv1.ShardDrainingPhase
+	moved := 0
+	if !wasDraining {
+		// The time is truncated to seconds so the reconciliation request is
+		// the same after the status is stored.
+		now := metav1.Now().Rfc3339Copy()
+		shard.Phase = templatesv1.ShardDrainingPhase
+		shard.DrainingSince = &now
+		shard.AssignedObjects = 0
+
+		resolved, err := assignment.Resolve(fluxShardSet)
+		if err != nil {
+			return shard, false, fmt.Errorf("failed to drain shard %s: %w", shard.Name, err)
+		}
+		moved, err = assignment.Drain(ctx, r.Client, kinds, resolved, active, shard.Name, drainRequest(shard))
+		if err != nil {
+			return shard, false, fmt.Errorf("failed to drain shard %s: %w", shard.Name, err)
+		}
+		if moved > 0 {
+			r.Eventf(fluxShardSet, corev1.EventTypeNormal, shardDrainingReason,
+				"Shard %s removed, %d object(s) moved", shard.Name, moved)
+		}
+	}
+
+	pending, err := assignment.Pending(ctx, r.Client, kinds, drainRequest(shard))
+	if err != nil {
+		return shard, false, fmt.Errorf("failed to check draining shard %s: %w", shard.Name, err)
+	}
+	shard.PendingObjects = pending
+
+	if pending == 0 {
+		if wasDraining || moved > 0 {
+			r.Eventf(fluxShardSet, corev1.EventTypeNormal, shardDrainedReason,
+				"Shard %s drained", shard.Name)
+		}
+		return shard, true, nil
+	}
+
+	if time.Since(shard.DrainingSince.Time) >= drainTimeout(fluxShardSet) {
+		r.Eventf(fluxShardSet, corev1.EventTypeWarning, drainTimeoutReason,
+			"Shard %s drain timed out with %d object(s) not yet reconciled", shard.Name, pending)
+		return shard, true, nil
+	}
+
+	return shard, false, nil
+}
+
+// drainRequest returns the reconciliation request for the objects moved from
+// a draining shard.
+func drainRequest(shard templatesv1.ShardStatus) string {
+	return fmt.Sprintf("drain-%s-%s", shard.Name, shard.DrainingSince.UTC().Format(time.RFC3339))
+}
+
+// drainTimeout returns how long removed shards are kept while they drain.
+func drainTimeout(fluxShardSet *templatesv1.FluxShardSet) time.Duration {
+	if fluxShardSet.Spec.DrainTimeout != nil {
+		return fluxShardSet.Spec.DrainTimeout.Duration
+	}
+
+	return defaultDrainTimeout
+}
+
+// reconcileAssignment assigns unassigned Flux objects to the shards if
+// assignment is configured and returns the number of objects assigned to each
+// shard.
+func (r *FluxShardSetReconciler) reconcileAssignment(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, shards []templatesv1.ShardSpec) (map[string]int, error) {
+	if fluxShardSet.Spec.Assignment == nil {
+		return map[string]int{}, nil
+	}
+
+	resolved, err := assignment.Resolve(fluxShardSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign objects to shards: %w", err)
+	}
+
+	// Shards in maintenance are left out so that objects are not assigned to
+	// them, and the objects assigned to them are not moved. Namespaces mapped
+	// to shards in maintenance are still mapped.
+	names := []string{}
+	maintenance := sets.New[string]()
+	for _, shard := range shards {
+		if shard.Maintenance && fluxShardSet.Spec.Assignment.Strategy != templatesv1.NamespaceStrategy {
+			maintenance.Insert(shard.Name)
+			continue
+		}
+		names = append(names, shard.Name)
+	}
+
+	previous := []string{}
+	for _, shard := range fluxShardSet.Status.Shards {
+		if !maintenance.Has(shard.Name) {
+			previous = append(previous, shard.Name)
+		}
+	}
+
+	counts, err := assignment.Assign(ctx, r.Client, resolved, names, previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign objects to shards: %w", err)
+	}
+
+	return counts, nil
+}
+
+// reconcileMissingSource applies the SourceMissingPolicy to the shard
+// Deployments when the source Deployment is not found.
+//
+// A stalling error is always returned, the FluxShardSet recovers when the
+// source Deployment is recreated.
+func (r *FluxShardSetReconciler) reconcileMissingSource(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet) error {
+	var refs []templatesv1.ResourceRef
+	if fluxShardSet.Status.Inventory != nil {
+		refs = fluxShardSet.Status.Inventory.Entries
+	}
+
+	switch fluxShardSet.Spec.SourceMissingPolicy {
+	case templatesv1.ScaleToZeroPolicy:
+		if err := r.scaleResourceRefsToZero(ctx, fluxShardSet, refs); err != nil {
+			return err
+		}
+		// The checksums are cleared so that the Deployments are applied again
+		// when the source Deployment is recreated, even if it's unchanged.
+		for i := range refs {
+			refs[i].Checksum = ""
+		}
+	case templatesv1.DeletePolicy:
+		if err := r.removeResourceRefs(ctx, fluxShardSet, refs); err != nil {
+			return err
+		}
+		fluxShardSet.Status.Inventory = nil
+		fluxShardSet.Status.Shards = nil
+	}
+
+	return &stallingError{
+		reason: templatesv1.SourceDeploymentNotFoundReason,
+		err: fmt.Errorf("source Deployment %s/%s not found",
+			fluxShardSet.GetNamespace(), fluxShardSet.Spec.SourceDeploymentRef.Name),
+	}
+}
+
+func (r *FluxShardSetReconciler) scaleResourceRefsToZero(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, refs []templatesv1.ResourceRef) error {
+	logger := log.FromContext(ctx)
+	for _, v := range refs {
+		d, err := deploymentFromResourceRef(v)
+		if err != nil {
+			return err
+		}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(d), d); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to load existing Deployment: %w", err)
+		}
+		if d.Spec.Replicas != nil && *d.Spec.Replicas == 0 {
+			continue
+		}
+
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+
+		patch := client.MergeFrom(d.DeepCopy())
+		if d.Annotations == nil {
+			d.Annotations = map[string]string{}
+		}
+		d.Annotations[scaledFromReplicasAnnotation] = strconv.Itoa(int(replicas))
+		d.Spec.Replicas = pointer.Int32(0)
+		if err := r.Client.Patch(ctx, d, patch, client.FieldOwner(fieldManager)); err != nil {
+			return fmt.Errorf("failed to scale Deployment %s to zero: %w", client.ObjectKeyFromObject(d), err)
+		}
+		if err := logResourceMessage(logger, "scaled deployment to zero", d); err != nil {
+			return err
+		}
+		r.Eventf(fluxShardSet, corev1.EventTypeNormal, deploymentUpdatedReason,
+			"Deployment %s scaled to zero", client.ObjectKeyFromObject(d))
+	}
+
+	return nil
+}
+
+// restoreScaledReplicas scales a Deployment that was scaled to zero while the
+// source Deployment was missing back to the recorded replicas.
+func (r *FluxShardSetReconciler) restoreScaledReplicas(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, d *appsv1.Deployment) error {
+	value, ok := d.GetAnnotations()[scaledFromReplicasAnnotation]
+	if !ok {
+		return nil
+	}
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s annotation of Deployment %s: %w", scaledFromReplicasAnnotation, client.ObjectKeyFromObject(d), err)
+	}
+
+	patch := client.MergeFrom(d.DeepCopy())
+	delete(d.Annotations, scaledFromReplicasAnnotation)
+	d.Spec.Replicas = pointer.Int32(int32(replicas))
+	if err := r.Client.Patch(ctx, d, patch, client.FieldOwner(fieldManager)); err != nil {
+		return fmt.Errorf("failed to scale Deployment %s: %w", client.ObjectKeyFromObject(d), err)
+	}
+	if err := logResourceMessage(log.FromContext(ctx), "scaled deployment up", d); err != nil {
+		return err
+	}
+	r.Eventf(fluxShardSet, corev1.EventTypeNormal, deploymentUpdatedReason,
+		"Deployment %s scaled to %d replica(s)", client.ObjectKeyFromObject(d), replicas)
+
+	return nil
+}
+
+// applyDeployment creates or updates the Deployment using server-side apply,
+// the controller only owns the fields in the generated Deployment so fields
+// set by other managers are not changed.
+func (r *FluxShardSetReconciler) applyDeployment(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, depl *appsv1.Deployment) error {
+	if err := controllerutil.SetOwnerReference(fluxShardSet, depl, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	// The controller takes ownership of the fields it generates, the
+	// generated Deployment is the desired state of the shard.
+	if err := r.Client.Patch(ctx, depl, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		return fmt.Errorf("failed to apply Deployment: %w", err)
+	}
+
+	return nil
+}
+
+// upgradeManagedFields moves the fields of the Deployment that are managed by
+// the legacyFieldManager to the fieldManager, so that fields that are no
+// longer generated are removed when the Deployment is applied.
+func (r *FluxShardSetReconciler) upgradeManagedFields(ctx context.Context, d *appsv1.Deployment) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(d, k8ssets.New(legacyFieldManager), fieldManager)
+	if err != nil {
+		return fmt.Errorf("failed to upgrade the managed fields of Deployment %s: %w", client.ObjectKeyFromObject(d), err)
+	}
+	if patch == nil {
+		return nil
+	}
+
+	if err := r.Client.Patch(ctx, d, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+		return fmt.Errorf("failed to upgrade the managed fields of Deployment %s: %w", client.ObjectKeyFromObject(d), err)
+	}
+
+	return nil
+}
+
+// isImmutableFieldError returns true if the error is from changing an
+// immutable field e.g. the selector of a Deployment.
+func isImmutableFieldError(err error) bool {
+	var statusErr *apierrors.StatusError
+	if !errors.As(err, &statusErr) || !apierrors.IsInvalid(statusErr) {
+		return false
+	}
+	details := statusErr.Status().Details
+	if details == nil {
+		return false
+	}
+	for _, cause := range details.Causes {
+		if strings.Contains(cause.Message, "field is immutable") {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (r *FluxShardSetReconciler) getSourceDeployment(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet) (*appsv1.Deployment, error) {
 	srcDeployKey := client.ObjectKey{
 		Name:      fluxShardSet.Spec.SourceDeploymentRef.Name,
@@ -228,22 +1231,56 @@ func (r *FluxShardSetReconciler) getSourceDeployment(ctx context.Context, fluxSh
 	return srcDeploy, nil
 }
 
-func (r *FluxShardSetReconciler) patchStatus(ctx context.Context, req ctrl.Request, newStatus templatesv1.FluxShardSetStatus) error {
-	var set templatesv1.FluxShardSet
-	if err := r.Client.Get(ctx, req.NamespacedName, &set); err != nil {
-		return err
+func (r *FluxShardSetReconciler) deploymentsToFluxShardSet(ctx context.Context, obj client.Object) []ctrl.Request {
+	return r.indexedFluxShardSets(ctx, deploymentIndexKey, obj)
+}
+
+func (r *FluxShardSetReconciler) configMapsToFluxShardSet(ctx context.Context, obj client.Object) []ctrl.Request {
+	return r.indexedFluxShardSets(ctx, configMapIndexKey, obj)
+}
+
+// fluxObjectsToFluxShardSet returns requests for the FluxShardSets that discover
+// shards from Flux objects or assign Flux objects to shards.
+func (r *FluxShardSetReconciler) fluxObjectsToFluxShardSet(ctx context.Context, obj client.Object) []ctrl.Request {
+	var list templatesv1.FluxShardSetList
+	if err := r.Client.List(ctx, &list); err != nil {
+		return nil
 	}
 
-	patch := client.MergeFrom(set.DeepCopy())
-	set.Status = newStatus
+	result := []reconcile.Request{}
+	for i := range list.Items {
+		if generators.UsesDiscovery(&list.Items[i]) || list.Items[i].Spec.Assignment != nil {
+			result = append(result, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
 
-	return r.Status().Patch(ctx, &set, patch)
+	return result
 }
 
-func (r *FluxShardSetReconciler) deploymentsToFluxShardSet(ctx context.Context, obj client.Object) []ctrl.Request {
+// namespacesToFluxShardSet returns requests for the FluxShardSets that assign
+// Flux objects to shards by namespace.
+func (r *FluxShardSetReconciler) namespacesToFluxShardSet(ctx context.Context, obj client.Object) []ctrl.Request {
+	var list templatesv1.FluxShardSetList
+	if err := r.Client.List(ctx, &list); err != nil {
+		return nil
+	}
+
+	result := []reconcile.Request{}
+	for i := range list.Items {
+		if a := list.Items[i].Spec.Assignment; a != nil && a.Strategy == templatesv1.NamespaceStrategy {
+			result = append(result, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+
+	return result
+}
+
+// indexedFluxShardSets returns requests for the FluxShardSets that reference
+// the object in the index.
+func (r *FluxShardSetReconciler) indexedFluxShardSets(ctx context.Context, indexKey string, obj client.Object) []ctrl.Request {
 	var list templatesv1.FluxShardSetList
 	if err := r.Client.List(ctx, &list,
-		client.MatchingFields{deploymentIndexKey: client.ObjectKeyFromObject(obj).String()},
+		client.MatchingFields{indexKey: client.ObjectKeyFromObject(obj).String()},
 		client.InNamespace(obj.GetNamespace())); err != nil {
 		return nil
 	}
@@ -256,6 +1293,21 @@ func (r *FluxShardSetReconciler) deploymentsToFluxShardSet(ctx context.Context,
 	return result
 }
 
+// stallingError is returned when the FluxShardSet can't be reconciled until
+// it or the source Deployment is changed.
+type stallingError struct {
+	reason string
+	err    error
+}
+
+func (e *stallingError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stallingError) Unwrap() error {
+	return e.err
+}
+
 func deploymentFromResourceRef(ref templatesv1.ResourceRef) (*appsv1.Deployment, error) {
 	objMeta, err := object.ParseObjMetadata(ref.ID)
 	if err != nil {
@@ -288,22 +1340,37 @@ func logResourceMessage(logger logr.Logger, msg string, obj runtime.Object) erro
 	return nil
 }
 
-func copyDeploymentContent(existing, newValue *appsv1.Deployment) *appsv1.Deployment {
-	result := &appsv1.Deployment{}
-	existing.DeepCopyInto(result)
-
-	result.Spec = newValue.Spec
-	result.SetAnnotations(newValue.GetAnnotations())
-	result.SetLabels(newValue.GetLabels())
+func indexDeployments(o client.Object) []string {
+	fss, ok := o.(*templatesv1.FluxShardSet)
+	if !ok {
+		panic(fmt.Sprintf("Expected a FluxShardSet, got %T", o))
+	}
 
-	return result
+	return []string{fmt.Sprintf("%s/%s", fss.GetNamespace(), fss.Spec.SourceDeploymentRef.Name)}
 }
 
-func indexDeployments(o client.Object) []string {
+func indexConfigMaps(o client.Object) []string {
 	fss, ok := o.(*templatesv1.FluxShardSet)
 	if !ok {
 		panic(fmt.Sprintf("Expected a FluxShardSet, got %T", o))
 	}
 
-	return []string{fmt.Sprintf("%s/%s", fss.GetNamespace(), fss.Spec.SourceDeploymentRef.Name)}
+	keys := []string{}
+	for _, key := range generators.ConfigMapKeys(fss) {
+		keys = append(keys, key.String())
+	}
+
+	return keys
+}
+
+// isOwnedBy returns true if the object has an owner reference to the
+// FluxShardSet.
+func isOwnedBy(obj metav1.Object, fluxShardSet *templatesv1.FluxShardSet) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == fluxShardSet.GetUID() {
+			return true
+		}
+	}
+
+	return false
 }
@@ -5,22 +5,32 @@ import (
 	"fmt"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
 	"github.com/weaveworks/flux-shard-controller/test"
 )
 
@@ -59,9 +69,11 @@ func TestReconciliation(t *testing.T) {
 	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: scheme})
 	test.AssertNoError(t, err)
 
+	// The FakeRecorder discards events if it has no channel.
 	reconciler := &FluxShardSetReconciler{
-		Client: k8sClient,
-		Scheme: scheme,
+		Client:        k8sClient,
+		EventRecorder: &record.FakeRecorder{},
+		Scheme:        scheme,
 	}
 
 	test.AssertNoError(t, reconciler.SetupWithManager(mgr))
@@ -108,12 +120,111 @@ func TestReconciliation(t *testing.T) {
 
 		// Check inventory updated with fluxshardset and new deployment(want) and condition of number of resources created
 		test.AssertInventoryHasItems(t, shardSet, want...)
-		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "1 shard(s) created")
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "1 shard(s) created, 1 not yet available")
 
 		// Check deployments existing include the new deployment
 		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1")
 	})
 
+	t.Run("reconciling reports the health of shard deployments", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "1 shard(s) created, 1 not yet available")
+		assertFluxShardSetCondition(t, shardSet, meta.ReconcilingCondition, "1 shard(s) created, 1 not yet available")
+		if health := shardSet.Status.Shards[0].Health; health != "InProgress" {
+			t.Fatalf("got shard health %q, want InProgress", health)
+		}
+
+		// The test environment doesn't run the Deployment controller, so the
+		// Deployment is marked as available.
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		shardDeployment.Status = availableDeploymentStatus(shardDeployment.Generation, 1)
+		test.AssertNoError(t, k8sClient.Status().Update(ctx, shardDeployment))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "1 shard(s) created")
+		if cond := apimeta.FindStatusCondition(shardSet.Status.Conditions, meta.ReconcilingCondition); cond != nil {
+			t.Fatalf("got Reconciling condition %#v, want none", cond)
+		}
+
+		want := []templatesv1.ShardStatus{
+			{
+				Name:              "shard-1",
+				Phase:             templatesv1.ShardActivePhase,
+				DeploymentRef:     &meta.NamespacedObjectReference{Name: "kustomize-controller-shard-1", Namespace: "default"},
+				Replicas:          1,
+				ReadyReplicas:     1,
+				AvailableReplicas: 1,
+				Image:             "ghcr.io/fluxcd/kustomize-controller:v0.35.1",
+				Health:            "Current",
+			},
+		}
+		if diff := cmp.Diff(want, shardSet.Status.Shards); diff != "" {
+			t.Fatalf("failed to report shard status:\n%s", diff)
+		}
+	})
+
+	t.Run("reconciling records events for shard deployments", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.Shards = []templatesv1.ShardSpec{
+				{
+					Name: "shard-1",
+				},
+				{
+					Name: "shard-2",
+				},
+			}
+		})
+
+		recorder := record.NewFakeRecorder(10)
+		eventsReconciler := &FluxShardSetReconciler{
+			Client:        k8sClient,
+			EventRecorder: recorder,
+			Scheme:        scheme,
+		}
+
+		reconcileAndReload(t, k8sClient, eventsReconciler, shardSet)
+		assertEvents(t, recorder,
+			"Normal DeploymentCreated Deployment default/kustomize-controller-shard-1 created",
+			"Normal DeploymentCreated Deployment default/kustomize-controller-shard-2 created")
+
+		shardSet.Spec.Shards = []templatesv1.ShardSpec{
+			{
+				Name: "shard-1",
+			},
+		}
+		test.AssertNoError(t, k8sClient.Update(ctx, shardSet))
+
+		reconcileAndReload(t, k8sClient, eventsReconciler, shardSet)
+		assertEvents(t, recorder,
+			"Normal DeploymentDeleted Deployment default/kustomize-controller-shard-2 deleted")
+	})
+
+	t.Run("reconciling creation of new deployments from generators", func(t *testing.T) {
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.Shards = []templatesv1.ShardSpec{
+				{
+					Name: "shard-a",
+				},
+			}
+			set.Spec.Generators = []templatesv1.ShardGenerator{
+				{
+					Range: &templatesv1.RangeGenerator{Count: 2},
+				},
+			}
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "3 shard(s) created, 3 not yet available")
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-0",
+			"kustomize-controller-shard-1", "kustomize-controller-shard-a")
+	})
+
 	t.Run("reconciling creation of new deployment when it already exists", func(t *testing.T) {
 		ctx := context.TODO()
 
@@ -152,17 +263,17 @@ func TestReconciliation(t *testing.T) {
 		defer deleteObject(t, k8sClient, shard1)
 
 		// Reconcile
-		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardSet)})
-		test.AssertErrorMatch(t, `failed to create Deployment: deployments.apps "kustomize-controller-shard-1" already exists`, err)
-
-		// reload the shardset
-		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(shardSet), shardSet))
+		reconcileWithErrorAndReload(t, k8sClient, reconciler, shardSet,
+			`failed to create Deployment: deployments.apps "kustomize-controller-shard-1" already exists`)
 
 		if shardSet.Status.Inventory != nil {
 			t.Errorf("expected Inventory to be nil, but got %v", shardSet.Status.Inventory)
 		}
 		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition,
 			`failed to create Deployment: deployments.apps "kustomize-controller-shard-1" already exists`)
+		// The error is retried so the FluxShardSet is still reconciling.
+		assertFluxShardSetCondition(t, shardSet, meta.ReconcilingCondition,
+			`failed to create Deployment: deployments.apps "kustomize-controller-shard-1" already exists`)
 	})
 
 	t.Run("Delete resources when removing shard from fluxshardset shards", func(t *testing.T) {
@@ -316,10 +427,15 @@ func TestReconciliation(t *testing.T) {
 		test.AssertNoError(t, k8sClient.Create(ctx, shardSet))
 		defer deleteFluxShardSet(t, k8sClient, shardSet)
 
+		// The FluxShardSet is stalled so the error isn't returned for retrying.
 		expectedErrMsg := "failed to generate deployments: deployment default/kustomize-controller is not configured to ignore sharding"
-		reconcileWithErrorAndReload(t, k8sClient, reconciler, shardSet, expectedErrMsg)
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
 
 		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, expectedErrMsg)
+		assertFluxShardSetCondition(t, shardSet, meta.StalledCondition, expectedErrMsg)
+		if cond := apimeta.FindStatusCondition(shardSet.Status.Conditions, meta.ReconcilingCondition); cond != nil {
+			t.Fatalf("got Reconciling condition %#v, want none", cond)
+		}
 	})
 
 	t.Run("Update generated deployments when src deployment updated existing annotations", func(t *testing.T) {
@@ -370,7 +486,7 @@ func TestReconciliation(t *testing.T) {
 		})
 		genDeployment := &appsv1.Deployment{}
 		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(shard1Deploy), genDeployment))
-		if diff := cmp.Diff(genDeployment, shard1Deploy, ignoreObjectMeta, ignoreTypeMeta); diff != "" {
+		if diff := cmp.Diff(genDeployment, shard1Deploy, ignoreObjectMeta, ignoreTypeMeta, test.IgnoreChecksumAnnotation); diff != "" {
 			t.Fatalf("Generated deployment does not match expected, diff: %s", diff)
 		}
 
@@ -388,7 +504,7 @@ func TestReconciliation(t *testing.T) {
 
 		updatedGenDepl := &appsv1.Deployment{}
 		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(shard1Deploy), updatedGenDepl))
-		if diff := cmp.Diff(updatedGenDepl, shard1Deploy, ignoreObjectMeta, ignoreTypeMeta); diff != "" {
+		if diff := cmp.Diff(updatedGenDepl, shard1Deploy, ignoreObjectMeta, ignoreTypeMeta, test.IgnoreChecksumAnnotation); diff != "" {
 			t.Fatalf("generated deployments don't match expected, diff: %s", diff)
 		}
 
@@ -439,7 +555,7 @@ func TestReconciliation(t *testing.T) {
 
 		genDeployment := &appsv1.Deployment{}
 		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(shard1Deploy), genDeployment))
-		if diff := cmp.Diff(genDeployment, shard1Deploy, ignoreObjectMeta, ignoreTypeMeta); diff != "" {
+		if diff := cmp.Diff(genDeployment, shard1Deploy, ignoreObjectMeta, ignoreTypeMeta, test.IgnoreChecksumAnnotation); diff != "" {
 			t.Fatalf("Generated deployment does not match expected, diff: %s", diff)
 		}
 
@@ -452,109 +568,1076 @@ func TestReconciliation(t *testing.T) {
 
 		updatedGenDepl := &appsv1.Deployment{}
 		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(shard1Deploy), updatedGenDepl))
-		if diff := cmp.Diff(updatedGenDepl, shard1Deploy, ignoreObjectMeta, ignoreTypeMeta); diff != "" {
+		if diff := cmp.Diff(updatedGenDepl, shard1Deploy, ignoreObjectMeta, ignoreTypeMeta, test.IgnoreChecksumAnnotation); diff != "" {
 			t.Fatalf("generated deployments don't match expected, diff: %s", diff)
 		}
 
 		test.AssertInventoryHasItems(t, shardSet, shard1Deploy)
 	})
-}
 
-func assertDeploymentsExist(t *testing.T, cl client.Client, ns string, want ...string) {
-	t.Helper()
-	d := &appsv1.DeploymentList{}
-	test.AssertNoError(t, cl.List(context.TODO(), d, client.InNamespace(ns)))
+	t.Run("applying deployments keeps fields set by other field managers", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient)
 
-	existingDeps := []string{}
-	for _, dep := range d.Items {
-		existingDeps = append(existingDeps, dep.Name)
-	}
-	if diff := cmp.Diff(want, existingDeps); diff != "" {
-		t.Fatalf("didn't find deployments, got different names: \n%s", diff)
-	}
-}
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
 
-func assertDeploymentsDontExist(t *testing.T, cl client.Client, ns string, deps ...string) {
-	t.Helper()
-	d := &appsv1.DeploymentList{}
-	test.AssertNoError(t, cl.List(context.TODO(), d, client.InNamespace(ns)))
+		// Restart the shard deployment as kubectl rollout restart does.
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		patch := client.MergeFrom(shardDeployment.DeepCopy())
+		shardDeployment.Spec.Template.Annotations = map[string]string{
+			"kubectl.kubernetes.io/restartedAt": "2023-06-01T10:00:00Z",
+		}
+		test.AssertNoError(t, k8sClient.Patch(ctx, shardDeployment, patch, client.FieldOwner("kubectl-rollout")))
 
-	existingDeps := []string{}
-	for _, dep := range d.Items {
-		existingDeps = append(existingDeps, dep.Name)
-	}
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
 
-	matches := []string{}
-	for _, dep := range deps {
-		for _, existingDep := range existingDeps {
-			if dep == existingDep {
-				matches = append(matches, dep)
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		if v := shardDeployment.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"]; v != "2023-06-01T10:00:00Z" {
+			t.Fatalf("got restartedAt annotation %q, want 2023-06-01T10:00:00Z", v)
+		}
+	})
+
+	t.Run("replicas are copied from the src deployment", func(t *testing.T) {
+		ctx := context.TODO()
+		srcDeployment, shardSet := newShardSetFixture(t, k8sClient)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 1)
+
+		srcDeployment.Spec.Replicas = pointer.Int32(2)
+		test.AssertNoError(t, k8sClient.Update(ctx, srcDeployment))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 2)
+	})
+
+	t.Run("replicas set by other field managers are kept", func(t *testing.T) {
+		ctx := context.TODO()
+		srcDeployment, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.ReplicasPolicy = templatesv1.ExternalReplicasPolicy
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		// Scale the shard deployment as kubectl scale does.
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		patch := client.MergeFrom(shardDeployment.DeepCopy())
+		shardDeployment.Spec.Replicas = pointer.Int32(3)
+		test.AssertNoError(t, k8sClient.Patch(ctx, shardDeployment, patch, client.FieldOwner("kubectl-scale")))
+
+		// Changing the source deployment applies the shard deployment again.
+		srcDeployment.Spec.Template.Spec.Containers[0].Image = "ghcr.io/fluxcd/kustomize-controller:v0.35.2"
+		test.AssertNoError(t, k8sClient.Update(ctx, srcDeployment))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 3)
+	})
+
+	t.Run("deployments owned by the fluxshardset that are not in the inventory are adopted", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		// Lose the inventory as a reconciliation that fails after applying
+		// does.
+		shardSet.Status.Inventory = nil
+		test.AssertNoError(t, k8sClient.Status().Update(ctx, shardSet))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		test.AssertInventoryHasItems(t, shardSet,
+			test.MakeTestDeployment(nsn("default", "kustomize-controller-shard-1")))
+	})
+
+	t.Run("deployments created by earlier versions are upgraded to server-side apply", func(t *testing.T) {
+		ctx := context.TODO()
+		srcDeployment, shardSet := newShardSetFixture(t, k8sClient)
+
+		// Create the shard deployment as earlier versions did, with an
+		// annotation that is no longer generated.
+		generated, err := deploys.GenerateDeployments(shardSet, srcDeployment, shardSet.Spec.Shards)
+		test.AssertNoError(t, err)
+		legacy := generated[0]
+		legacy.Annotations = map[string]string{"example.com/legacy": "true"}
+		test.AssertNoError(t, controllerutil.SetOwnerReference(shardSet, legacy, scheme))
+		test.AssertNoError(t, k8sClient.Create(ctx, legacy, client.FieldOwner(legacyFieldManager)))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		if _, ok := shardDeployment.GetAnnotations()["example.com/legacy"]; ok {
+			t.Errorf("annotation that is no longer generated was not removed")
+		}
+		for _, entry := range shardDeployment.GetManagedFields() {
+			if entry.Manager == legacyFieldManager {
+				t.Errorf("fields are still managed by %s: %s", legacyFieldManager, entry.FieldsV1.Raw)
 			}
 		}
-	}
-	if len(matches) > 0 {
-		cmp.Diff(matches, []string{})
-		t.Fatalf("found deployments that shouldn't be found:\n%s", cmp.Diff(matches, []string{}))
-	}
-}
+	})
 
-func assertFluxShardSetCondition(t *testing.T, shardset *templatesv1.FluxShardSet, condType, msg string) {
-	t.Helper()
-	cond := apimeta.FindStatusCondition(shardset.Status.Conditions, condType)
-	if cond == nil {
-		t.Fatalf("failed to find matching status condition for type %s in %#v", condType, shardset.Status.Conditions)
-	}
-	if cond.Message != msg {
-		t.Fatalf("got %s, want %s", cond.Message, msg)
-	}
-}
+	t.Run("unchanged deployments are not applied again", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient)
 
-// this runs a single reconciliation
-func reconcileAndReload(t *testing.T, cl client.Client, reconciler *FluxShardSetReconciler, shardset *templatesv1.FluxShardSet) {
-	t.Helper()
-	ctx := context.TODO()
-	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardset)})
-	test.AssertNoError(t, err)
+		applied := deploymentWrites(t, shardSet, "applied")
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		if v := deploymentWrites(t, shardSet, "applied"); v != applied+1 {
+			t.Fatalf("got %v applied writes, want %v", v, applied+1)
+		}
 
-	test.AssertNoError(t, cl.Get(ctx, client.ObjectKeyFromObject(shardset), shardset))
-}
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		checksum := shardDeployment.Annotations["templates.weave.works/checksum"]
+		if checksum == "" || shardSet.Status.Inventory.Entries[0].Checksum != checksum {
+			t.Fatalf("got inventory checksum %q, want %q", shardSet.Status.Inventory.Entries[0].Checksum, checksum)
+		}
 
-func reconcileWithErrorAndReload(t *testing.T, cl client.Client, reconciler *FluxShardSetReconciler, shardSet *templatesv1.FluxShardSet, expectedErrMsg string) {
-	t.Helper()
-	ctx := context.TODO()
-	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardSet)})
-	// Check for error matching expected error
-	test.AssertErrorMatch(t, expectedErrMsg, err)
+		skipped := deploymentWrites(t, shardSet, "skipped")
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		if v := deploymentWrites(t, shardSet, "skipped"); v != skipped+1 {
+			t.Fatalf("got %v skipped writes, want %v", v, skipped+1)
+		}
+	})
 
-	// reload
-	test.AssertNoError(t, cl.Get(ctx, client.ObjectKeyFromObject(shardSet), shardSet))
-}
+	t.Run("drifted deployments are corrected", func(t *testing.T) {
+		ctx := context.TODO()
+		srcDeployment, shardSet := newShardSetFixture(t, k8sClient)
 
-func deleteFluxShardSet(t *testing.T, cl client.Client, shardset *templatesv1.FluxShardSet) {
-	ctx := context.TODO()
-	t.Helper()
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
 
-	test.AssertNoError(t, cl.Get(ctx, client.ObjectKeyFromObject(shardset), shardset))
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		patch := client.MergeFrom(shardDeployment.DeepCopy())
+		shardDeployment.Spec.Template.Spec.Containers[0].Image = "example.com/kustomize-controller:latest"
+		test.AssertNoError(t, k8sClient.Patch(ctx, shardDeployment, patch, client.FieldOwner("kubectl-edit")))
 
-	if shardset.Status.Inventory != nil {
-		for _, v := range shardset.Status.Inventory.Entries {
-			d, err := deploymentFromResourceRef(v)
-			test.AssertNoError(t, err)
-			test.AssertNoError(t, cl.Delete(ctx, d))
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		if image := shardDeployment.Spec.Template.Spec.Containers[0].Image; image != srcDeployment.Spec.Template.Spec.Containers[0].Image {
+			t.Errorf("got image %q, want %q", image, srcDeployment.Spec.Template.Spec.Containers[0].Image)
 		}
-	}
+		assertFluxShardSetCondition(t, shardSet, templatesv1.DriftedCondition, "all shard Deployments match the generated Deployments")
+	})
 
-	test.AssertNoError(t, cl.Delete(ctx, shardset))
-}
+	t.Run("drift from updates by other field managers is corrected with the default configuration", func(t *testing.T) {
+		ctx := context.TODO()
+		srcDeployment, shardSet := newShardSetFixture(t, k8sClient)
+		defaultReconciler := &FluxShardSetReconciler{
+			Client:        k8sClient,
+			EventRecorder: &record.FakeRecorder{},
+			Scheme:        scheme,
+		}
 
-func nsn(namespace, name string) types.NamespacedName {
-	return types.NamespacedName{
-		Name:      name,
-		Namespace: namespace,
-	}
-}
+		reconcileAndReload(t, k8sClient, defaultReconciler, shardSet)
 
-func deleteObject(t *testing.T, cl client.Client, obj client.Object) {
-	t.Helper()
-	test.AssertNoError(t, cl.Delete(context.TODO(), obj))
+		// Update the shard deployment as kubectl edit does, the Update takes
+		// ownership of the image from the shard controller.
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		shardDeployment.Spec.Template.Spec.Containers[0].Image = "example.com/kustomize-controller:latest"
+		test.AssertNoError(t, k8sClient.Update(ctx, shardDeployment, client.FieldOwner("kubectl-edit")))
+
+		_, err := defaultReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardSet)})
+		test.AssertNoError(t, err)
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(shardSet), shardSet))
+
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		if image := shardDeployment.Spec.Template.Spec.Containers[0].Image; image != srcDeployment.Spec.Template.Spec.Containers[0].Image {
+			t.Errorf("got image %q, want %q", image, srcDeployment.Spec.Template.Spec.Containers[0].Image)
+		}
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "1 shard(s) created, 1 not yet available")
+		assertFluxShardSetCondition(t, shardSet, templatesv1.DriftedCondition, "all shard Deployments match the generated Deployments")
+	})
+
+	t.Run("drifted deployments are reported", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.DriftPolicy = templatesv1.ReportDriftPolicy
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		patch := client.MergeFrom(shardDeployment.DeepCopy())
+		shardDeployment.Spec.Template.Spec.Containers[0].Image = "example.com/kustomize-controller:latest"
+		test.AssertNoError(t, k8sClient.Patch(ctx, shardDeployment, patch, client.FieldOwner("kubectl-edit")))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertFluxShardSetCondition(t, shardSet, templatesv1.DriftedCondition, "1 Deployment(s) have drifted: default/kustomize-controller-shard-1 (spec.template.spec.containers[0].image)")
+	})
+
+	t.Run("scaled deployments are not drifted", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.ReplicasPolicy = templatesv1.ExternalReplicasPolicy
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		patch := client.MergeFrom(shardDeployment.DeepCopy())
+		shardDeployment.Spec.Replicas = pointer.Int32(5)
+		test.AssertNoError(t, k8sClient.Patch(ctx, shardDeployment, patch, client.FieldOwner("kubectl-scale")))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 5)
+		assertFluxShardSetCondition(t, shardSet, templatesv1.DriftedCondition, "all shard Deployments match the generated Deployments")
+	})
+
+	t.Run("reconciling requeues at the interval", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.Interval = &metav1.Duration{Duration: 2 * time.Minute}
+		})
+
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardSet)})
+		test.AssertNoError(t, err)
+
+		if result.RequeueAfter != 2*time.Minute {
+			t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, 2*time.Minute)
+		}
+	})
+
+	t.Run("failed reconciliations are requeued at the retry interval", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.RetryInterval = &metav1.Duration{Duration: 30 * time.Second}
+		})
+
+		// The shard Deployment already exists so the reconciliation fails.
+		shard1 := test.MakeTestDeployment(nsn("default", "kustomize-controller-shard-1"))
+		test.AssertNoError(t, k8sClient.Create(ctx, shard1))
+		defer deleteObject(t, k8sClient, shard1)
+
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardSet)})
+		test.AssertNoError(t, err)
+
+		if result.RequeueAfter != 30*time.Second {
+			t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, 30*time.Second)
+		}
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(shardSet), shardSet))
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition,
+			`failed to create Deployment: deployments.apps "kustomize-controller-shard-1" already exists`)
+	})
+
+	t.Run("deployments are recreated when the selector changes", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		original := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), original))
+
+		shardSet.Spec.Patches = []templatesv1.Patch{
+			{
+				Patch: selectorPatch,
+			},
+		}
+		test.AssertNoError(t, k8sClient.Update(ctx, shardSet))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		// The old Deployment is deleted in the foreground, and the new
+		// Deployment is not created until it has been removed.
+		assertRecreatePhase(t, shardSet, templatesv1.RecreateDeletingPhase)
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "1 shard(s) created, 1 recreating")
+		removeForegroundDeletion(t, k8sClient, nsn("default", "kustomize-controller-shard-1"))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		recreated := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), recreated))
+		if recreated.UID == original.UID {
+			t.Fatal("the Deployment was not recreated")
+		}
+		if v := recreated.Spec.Selector.MatchLabels["tier"]; v != "shards" {
+			t.Fatalf("got selector label tier %q, want shards", v)
+		}
+		assertRecreatePhase(t, shardSet, "")
+		if shardSet.Status.Shards[0].RecreatedAt == nil {
+			t.Fatal("expected the shard status to record when the Deployment was recreated")
+		}
+	})
+
+	t.Run("deployments are recreated with a temporary deployment with the CreateFirst strategy", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.RecreateStrategy = templatesv1.CreateFirstRecreateStrategy
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		original := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), original))
+
+		shardSet.Spec.Patches = []templatesv1.Patch{
+			{
+				Patch: selectorPatch,
+			},
+		}
+		test.AssertNoError(t, k8sClient.Update(ctx, shardSet))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		// The old Deployment is kept until the temporary Deployment is
+		// available.
+		assertRecreatePhase(t, shardSet, templatesv1.RecreateStartingTemporaryPhase)
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1", "kustomize-controller-shard-1-recreate")
+		markDeploymentAvailable(t, k8sClient, nsn("default", "kustomize-controller-shard-1-recreate"))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertRecreatePhase(t, shardSet, templatesv1.RecreateDeletingPhase)
+		removeForegroundDeletion(t, k8sClient, nsn("default", "kustomize-controller-shard-1"))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		// The temporary Deployment is kept until the recreated Deployment is
+		// available.
+		assertRecreatePhase(t, shardSet, templatesv1.RecreateStartingPhase)
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1", "kustomize-controller-shard-1-recreate")
+		markDeploymentAvailable(t, k8sClient, nsn("default", "kustomize-controller-shard-1"))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertRecreatePhase(t, shardSet, "")
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1")
+		recreated := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), recreated))
+		if recreated.UID == original.UID {
+			t.Fatal("the Deployment was not recreated")
+		}
+		if shardSet.Status.Shards[0].RecreatedAt == nil {
+			t.Fatal("expected the shard status to record when the Deployment was recreated")
+		}
+	})
+
+	t.Run("deleting the fluxshardset deletes the deployments", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		if !controllerutil.ContainsFinalizer(shardSet, templatesv1.FluxShardSetFinalizer) {
+			t.Fatalf("got finalizers %v, want %s", shardSet.Finalizers, templatesv1.FluxShardSetFinalizer)
+		}
+
+		test.AssertNoError(t, k8sClient.Delete(ctx, shardSet))
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardSet)})
+		test.AssertNoError(t, err)
+
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(shardSet), shardSet); !apierrors.IsNotFound(err) {
+			t.Fatalf("expected the FluxShardSet to be deleted, got %v", err)
+		}
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller")
+	})
+
+	t.Run("deleting the fluxshardset with the orphan policy keeps the deployments", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.DeletionPolicy = templatesv1.OrphanDeletionPolicy
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		test.AssertNoError(t, k8sClient.Delete(ctx, shardSet))
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardSet)})
+		test.AssertNoError(t, err)
+
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		defer deleteObject(t, k8sClient, shardDeployment)
+		if len(shardDeployment.OwnerReferences) != 0 {
+			t.Fatalf("got owner references %v, want none", shardDeployment.OwnerReferences)
+		}
+	})
+
+	t.Run("removed shards are drained before their deployments are deleted", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.Shards = []templatesv1.ShardSpec{
+				{
+					Name: "shard-1",
+				},
+				{
+					Name: "shard-2",
+				},
+			}
+		})
+
+		kustomization := &unstructured.Unstructured{}
+		kustomization.SetGroupVersionKind(schema.GroupVersionKind{Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Kind: "Kustomization"})
+		kustomization.SetNamespace("default")
+		kustomization.SetName("app-1")
+		kustomization.SetLabels(map[string]string{"sharding.fluxcd.io/key": "shard-2"})
+		kustomization.Object["spec"] = map[string]interface{}{
+			"interval":  "5m",
+			"prune":     true,
+			"sourceRef": map[string]interface{}{"kind": "GitRepository", "name": "flux-system"},
+		}
+		test.AssertNoError(t, k8sClient.Create(ctx, kustomization))
+		defer deleteObject(t, k8sClient, kustomization)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		shardSet.Spec.Shards = []templatesv1.ShardSpec{
+			{
+				Name: "shard-1",
+			},
+		}
+		test.AssertNoError(t, k8sClient.Update(ctx, shardSet))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1", "kustomize-controller-shard-2")
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "1 shard(s) created, 1 draining")
+		draining := shardSet.Status.Shards[1]
+		if draining.Name != "shard-2" || draining.Phase != templatesv1.ShardDrainingPhase || draining.PendingObjects != 1 {
+			t.Fatalf("got shard status %#v, want shard-2 draining with 1 pending object", draining)
+		}
+
+		// The Kustomization is moved to the main controller, which handles
+		// the reconciliation request.
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(kustomization), kustomization))
+		if key, ok := kustomization.GetLabels()["sharding.fluxcd.io/key"]; ok {
+			t.Fatalf("got shard key %q, want no shard key", key)
+		}
+		requestedAt := kustomization.GetAnnotations()[meta.ReconcileRequestAnnotation]
+		test.AssertNoError(t, unstructured.SetNestedField(kustomization.Object, requestedAt, "status", "lastHandledReconcileAt"))
+		test.AssertNoError(t, k8sClient.Status().Update(ctx, kustomization))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1")
+		if len(shardSet.Status.Shards) != 1 {
+			t.Fatalf("got %d shard statuses, want 1", len(shardSet.Status.Shards))
+		}
+	})
+
+	t.Run("removed shards served by other fluxshardsets are not drained", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.Shards = []templatesv1.ShardSpec{
+				{
+					Name: "shard-1",
+				},
+				{
+					Name: "shard-2",
+				},
+			}
+		})
+
+		otherSet := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+			set.Name = "other-shard-set"
+			set.Spec.Suspend = true
+			set.Spec.SourceDeploymentRef = templatesv1.SourceDeploymentReference{
+				Name: "kustomize-controller",
+			}
+			set.Spec.Shards = []templatesv1.ShardSpec{
+				{
+					Name: "shard-2",
+				},
+			}
+		})
+		test.AssertNoError(t, k8sClient.Create(ctx, otherSet))
+		defer deleteObject(t, k8sClient, otherSet)
+		otherSet.Status.Shards = []templatesv1.ShardStatus{
+			{
+				Name: "shard-2",
+			},
+		}
+		test.AssertNoError(t, k8sClient.Status().Update(ctx, otherSet))
+
+		kustomization := &unstructured.Unstructured{}
+		kustomization.SetGroupVersionKind(schema.GroupVersionKind{Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Kind: "Kustomization"})
+		kustomization.SetNamespace("default")
+		kustomization.SetName("app-1")
+		kustomization.SetLabels(map[string]string{"sharding.fluxcd.io/key": "shard-2"})
+		kustomization.Object["spec"] = map[string]interface{}{
+			"interval":  "5m",
+			"prune":     true,
+			"sourceRef": map[string]interface{}{"kind": "GitRepository", "name": "flux-system"},
+		}
+		test.AssertNoError(t, k8sClient.Create(ctx, kustomization))
+		defer deleteObject(t, k8sClient, kustomization)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		shardSet.Spec.Shards = []templatesv1.ShardSpec{
+			{
+				Name: "shard-1",
+			},
+		}
+		test.AssertNoError(t, k8sClient.Update(ctx, shardSet))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1")
+		if len(shardSet.Status.Shards) != 1 {
+			t.Fatalf("got %d shard statuses, want 1", len(shardSet.Status.Shards))
+		}
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(kustomization), kustomization))
+		if key := kustomization.GetLabels()["sharding.fluxcd.io/key"]; key != "shard-2" {
+			t.Fatalf("got shard key %q, want %q", key, "shard-2")
+		}
+	})
+
+	t.Run("suspended shards are not reconciled", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		shardSet.Spec.Shards[0].Suspend = true
+		test.AssertNoError(t, k8sClient.Update(ctx, shardSet))
+
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		patch := client.MergeFrom(shardDeployment.DeepCopy())
+		shardDeployment.Spec.Replicas = pointer.Int32(0)
+		test.AssertNoError(t, k8sClient.Patch(ctx, shardDeployment, patch, client.FieldOwner("kubectl-scale")))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 0)
+		test.AssertInventoryHasItems(t, shardSet,
+			test.MakeTestDeployment(nsn("default", "kustomize-controller-shard-1")))
+		if phase := shardSet.Status.Shards[0].Phase; phase != templatesv1.ShardSuspendedPhase {
+			t.Fatalf("got shard phase %q, want %q", phase, templatesv1.ShardSuspendedPhase)
+		}
+	})
+
+	t.Run("suspended shards are kept when their deployments are deleted", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient)
+
+		kustomization := &unstructured.Unstructured{}
+		kustomization.SetGroupVersionKind(schema.GroupVersionKind{Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Kind: "Kustomization"})
+		kustomization.SetNamespace("default")
+		kustomization.SetName("app-1")
+		kustomization.SetLabels(map[string]string{"sharding.fluxcd.io/key": "shard-1"})
+		kustomization.Object["spec"] = map[string]interface{}{
+			"interval":  "5m",
+			"prune":     true,
+			"sourceRef": map[string]interface{}{"kind": "GitRepository", "name": "flux-system"},
+		}
+		test.AssertNoError(t, k8sClient.Create(ctx, kustomization))
+		defer deleteObject(t, k8sClient, kustomization)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		shardSet.Spec.Shards[0].Suspend = true
+		test.AssertNoError(t, k8sClient.Update(ctx, shardSet))
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		test.AssertNoError(t, k8sClient.Delete(ctx, shardDeployment))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		test.AssertInventoryHasItems(t, shardSet,
+			test.MakeTestDeployment(nsn("default", "kustomize-controller-shard-1")))
+		if len(shardSet.Status.Shards) != 1 {
+			t.Fatalf("got %d shard statuses, want 1", len(shardSet.Status.Shards))
+		}
+		if shard := shardSet.Status.Shards[0]; shard.Phase != templatesv1.ShardSuspendedPhase || shard.DeploymentRef == nil {
+			t.Fatalf("got shard status %#v, want shard-1 suspended with a Deployment", shard)
+		}
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(kustomization), kustomization))
+		if key := kustomization.GetLabels()["sharding.fluxcd.io/key"]; key != "shard-1" {
+			t.Fatalf("got shard key %q, want %q", key, "shard-1")
+		}
+
+		// The Deployment is created again when the shard is resumed.
+		shardSet.Spec.Shards[0].Suspend = false
+		test.AssertNoError(t, k8sClient.Update(ctx, shardSet))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1")
+	})
+
+	t.Run("shards in maintenance are scaled to zero", func(t *testing.T) {
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.Shards = []templatesv1.ShardSpec{
+				{
+					Name:        "shard-1",
+					Maintenance: true,
+				},
+			}
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 0)
+		if phase := shardSet.Status.Shards[0].Phase; phase != templatesv1.ShardMaintenancePhase {
+			t.Fatalf("got shard phase %q, want %q", phase, templatesv1.ShardMaintenancePhase)
+		}
+	})
+
+	t.Run("rollouts update the canary shard first", func(t *testing.T) {
+		ctx := context.TODO()
+		srcDeployment, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.Shards = []templatesv1.ShardSpec{
+				{
+					Name: "shard-1",
+				},
+				{
+					Name: "shard-2",
+				},
+			}
+			set.Spec.RolloutStrategy = &templatesv1.RolloutStrategy{
+				CanaryShard:    "shard-2",
+				MaxUnavailable: 2,
+			}
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		srcDeployment.Spec.Template.Spec.Containers[0].Image = "ghcr.io/fluxcd/kustomize-controller:v0.35.2"
+		test.AssertNoError(t, k8sClient.Update(ctx, srcDeployment))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		wantImages := map[string]string{
+			"kustomize-controller-shard-1": "ghcr.io/fluxcd/kustomize-controller:v0.35.1",
+			"kustomize-controller-shard-2": "ghcr.io/fluxcd/kustomize-controller:v0.35.2",
+		}
+		for name, want := range wantImages {
+			depl := &appsv1.Deployment{}
+			test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", name), depl))
+			if image := depl.Spec.Template.Spec.Containers[0].Image; image != want {
+				t.Errorf("Deployment %s got image %q, want %q", name, image, want)
+			}
+		}
+
+		want := &templatesv1.RolloutStatus{
+			Revision:      shardSet.Status.Rollout.Revision,
+			UpdatedShards: []string{"shard-2"},
+			PendingShards: []string{"shard-1"},
+		}
+		if diff := cmp.Diff(want, shardSet.Status.Rollout); diff != "" {
+			t.Fatalf("failed to record rollout:\n%s", diff)
+		}
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "2 shard(s) created, 2 rolling out")
+	})
+
+	t.Run("restart annotations restart the selected shards", func(t *testing.T) {
+		ctx := context.TODO()
+		_, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.Shards = []templatesv1.ShardSpec{
+				{
+					Name: "shard-1",
+				},
+				{
+					Name: "shard-2",
+				},
+			}
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		shardSet.SetAnnotations(map[string]string{
+			templatesv1.RestartedAtAnnotation:   "2023-06-01T00:00:00Z",
+			templatesv1.RestartShardsAnnotation: "shard-2",
+		})
+		test.AssertNoError(t, k8sClient.Update(ctx, shardSet))
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		wantRestarts := map[string]string{
+			"kustomize-controller-shard-1": "",
+			"kustomize-controller-shard-2": "2023-06-01T00:00:00Z",
+		}
+		for name, want := range wantRestarts {
+			depl := &appsv1.Deployment{}
+			test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", name), depl))
+			if restartedAt := depl.Spec.Template.Annotations[templatesv1.RestartedAtAnnotation]; restartedAt != want {
+				t.Errorf("Deployment %s got restartedAt %q, want %q", name, restartedAt, want)
+			}
+		}
+		if restartedAt := shardSet.Status.Shards[1].RestartedAt; restartedAt != "2023-06-01T00:00:00Z" {
+			t.Fatalf("got shard restartedAt %q, want 2023-06-01T00:00:00Z", restartedAt)
+		}
+	})
+
+	t.Run("scale deployments to zero when the src deployment is missing", func(t *testing.T) {
+		ctx := context.TODO()
+		srcDeployment, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.SourceMissingPolicy = templatesv1.ScaleToZeroPolicy
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1")
+
+		deleteObject(t, k8sClient, srcDeployment)
+
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardSet)})
+		test.AssertNoError(t, err)
+		if result.RequeueAfter != 0 {
+			t.Fatalf("got RequeueAfter %v, want the source Deployment watch to trigger reconciliation", result.RequeueAfter)
+		}
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(shardSet), shardSet))
+
+		expectedMsg := "source Deployment default/kustomize-controller not found"
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, expectedMsg)
+		assertFluxShardSetCondition(t, shardSet, meta.StalledCondition, expectedMsg)
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 0)
+
+		// Recreating the src deployment scales up the shard deployments.
+		srcDeployment = test.MakeTestDeployment(nsn("default", "kustomize-controller"), func(d *appsv1.Deployment) {
+			d.Spec.Template.Spec.Containers[0].Args = []string{
+				"--watch-label-selector=!sharding.fluxcd.io/key",
+			}
+		})
+		test.AssertNoError(t, k8sClient.Create(ctx, srcDeployment))
+		defer deleteObject(t, k8sClient, srcDeployment)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "1 shard(s) created, 1 not yet available")
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 1)
+	})
+
+	t.Run("scaled deployments are restored when the src deployment is recreated", func(t *testing.T) {
+		ctx := context.TODO()
+		srcDeployment, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.SourceMissingPolicy = templatesv1.ScaleToZeroPolicy
+			set.Spec.DriftPolicy = templatesv1.ReportDriftPolicy
+			set.Spec.ReplicasPolicy = templatesv1.ExternalReplicasPolicy
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		shardDeployment := &appsv1.Deployment{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		patch := client.MergeFrom(shardDeployment.DeepCopy())
+		shardDeployment.Spec.Replicas = pointer.Int32(2)
+		test.AssertNoError(t, k8sClient.Patch(ctx, shardDeployment, patch, client.FieldOwner("kubectl-scale")))
+
+		deleteObject(t, k8sClient, srcDeployment)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 0)
+
+		// Recreating the unchanged src deployment restores the replicas even
+		// though drift is only reported.
+		srcDeployment = test.MakeTestDeployment(nsn("default", "kustomize-controller"), func(d *appsv1.Deployment) {
+			d.Spec.Template.Spec.Containers[0].Args = []string{
+				"--watch-label-selector=!sharding.fluxcd.io/key",
+			}
+		})
+		test.AssertNoError(t, k8sClient.Create(ctx, srcDeployment))
+		defer deleteObject(t, k8sClient, srcDeployment)
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		assertDeploymentReplicas(t, k8sClient, nsn("default", "kustomize-controller-shard-1"), 2)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "kustomize-controller-shard-1"), shardDeployment))
+		if _, ok := shardDeployment.GetAnnotations()[scaledFromReplicasAnnotation]; ok {
+			t.Errorf("%s annotation was not removed", scaledFromReplicasAnnotation)
+		}
+	})
+
+	t.Run("delete deployments when the src deployment is missing", func(t *testing.T) {
+		srcDeployment, shardSet := newShardSetFixture(t, k8sClient, func(set *templatesv1.FluxShardSet) {
+			set.Spec.SourceMissingPolicy = templatesv1.DeletePolicy
+		})
+
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+		assertDeploymentsExist(t, k8sClient, "default", "kustomize-controller", "kustomize-controller-shard-1")
+
+		deleteObject(t, k8sClient, srcDeployment)
+		reconcileAndReload(t, k8sClient, reconciler, shardSet)
+
+		assertFluxShardSetCondition(t, shardSet, meta.ReadyCondition, "source Deployment default/kustomize-controller not found")
+		assertDeploymentsDontExist(t, k8sClient, "default", "kustomize-controller-shard-1")
+		if shardSet.Status.Inventory != nil {
+			t.Errorf("expected Inventory to be nil, but got %v", shardSet.Status.Inventory)
+		}
+	})
+}
+
+// deploymentWrites returns the number of shard Deployment writes with the
+// result recorded for the FluxShardSet.
+func deploymentWrites(t *testing.T, shardSet *templatesv1.FluxShardSet, result string) float64 {
+	t.Helper()
+	families, err := crmetrics.Registry.Gather()
+	test.AssertNoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "flux_shard_controller_deployment_writes_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["namespace"] == shardSet.Namespace && labels["name"] == shardSet.Name && labels["result"] == result {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
+
+func assertEvents(t *testing.T, recorder *record.FakeRecorder, want ...string) {
+	t.Helper()
+	got := []string{}
+	for len(recorder.Events) > 0 {
+		got = append(got, <-recorder.Events)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("failed to record events:\n%s", diff)
+	}
+}
+
+func assertDeploymentReplicas(t *testing.T, cl client.Client, name types.NamespacedName, want int32) {
+	t.Helper()
+	d := &appsv1.Deployment{}
+	test.AssertNoError(t, cl.Get(context.TODO(), name, d))
+
+	if got := *d.Spec.Replicas; got != want {
+		t.Fatalf("got %d replicas for Deployment %s, want %d", got, name, want)
+	}
+}
+
+func assertDeploymentsExist(t *testing.T, cl client.Client, ns string, want ...string) {
+	t.Helper()
+	d := &appsv1.DeploymentList{}
+	test.AssertNoError(t, cl.List(context.TODO(), d, client.InNamespace(ns)))
+
+	existingDeps := []string{}
+	for _, dep := range d.Items {
+		existingDeps = append(existingDeps, dep.Name)
+	}
+	if diff := cmp.Diff(want, existingDeps); diff != "" {
+		t.Fatalf("didn't find deployments, got different names: \n%s", diff)
+	}
+}
+
+func assertDeploymentsDontExist(t *testing.T, cl client.Client, ns string, deps ...string) {
+	t.Helper()
+	d := &appsv1.DeploymentList{}
+	test.AssertNoError(t, cl.List(context.TODO(), d, client.InNamespace(ns)))
+
+	existingDeps := []string{}
+	for _, dep := range d.Items {
+		existingDeps = append(existingDeps, dep.Name)
+	}
+
+	matches := []string{}
+	for _, dep := range deps {
+		for _, existingDep := range existingDeps {
+			if dep == existingDep {
+				matches = append(matches, dep)
+			}
+		}
+	}
+	if len(matches) > 0 {
+		cmp.Diff(matches, []string{})
+		t.Fatalf("found deployments that shouldn't be found:\n%s", cmp.Diff(matches, []string{}))
+	}
+}
+
+func assertFluxShardSetCondition(t *testing.T, shardset *templatesv1.FluxShardSet, condType, msg string) {
+	t.Helper()
+	cond := apimeta.FindStatusCondition(shardset.Status.Conditions, condType)
+	if cond == nil {
+		t.Fatalf("failed to find matching status condition for type %s in %#v", condType, shardset.Status.Conditions)
+	}
+	if cond.Message != msg {
+		t.Fatalf("got %s, want %s", cond.Message, msg)
+	}
+}
+
+// this runs a single reconciliation
+func reconcileAndReload(t *testing.T, cl client.Client, reconciler *FluxShardSetReconciler, shardset *templatesv1.FluxShardSet) {
+	t.Helper()
+	ctx := context.TODO()
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardset)})
+	test.AssertNoError(t, err)
+
+	test.AssertNoError(t, cl.Get(ctx, client.ObjectKeyFromObject(shardset), shardset))
+}
+
+func reconcileWithErrorAndReload(t *testing.T, cl client.Client, reconciler *FluxShardSetReconciler, shardSet *templatesv1.FluxShardSet, expectedErrMsg string) {
+	t.Helper()
+	ctx := context.TODO()
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(shardSet)})
+	// Check for error matching expected error
+	test.AssertErrorMatch(t, expectedErrMsg, err)
+
+	// reload
+	test.AssertNoError(t, cl.Get(ctx, client.ObjectKeyFromObject(shardSet), shardSet))
+}
+
+// newShardSetFixture creates a source Deployment, and a FluxShardSet with the
+// shard "shard-1" that generates shard Deployments from it. The options are
+// applied to the FluxShardSet before it's created.
+//
+// The FluxShardSet, its shard Deployments and the source Deployment are
+// deleted when the test completes, if they still exist.
+func newShardSetFixture(t *testing.T, cl client.Client, opts ...func(*templatesv1.FluxShardSet)) (*appsv1.Deployment, *templatesv1.FluxShardSet) {
+	t.Helper()
+	ctx := context.TODO()
+	srcDeployment := test.MakeTestDeployment(nsn("default", "kustomize-controller"), func(d *appsv1.Deployment) {
+		d.Spec.Template.Spec.Containers[0].Args = []string{
+			"--watch-label-selector=!sharding.fluxcd.io/key",
+		}
+	})
+	test.AssertNoError(t, cl.Create(ctx, srcDeployment))
+	t.Cleanup(func() {
+		test.AssertNoError(t, client.IgnoreNotFound(cl.Delete(ctx, srcDeployment)))
+	})
+
+	shardSet := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+		set.Spec.SourceDeploymentRef = templatesv1.SourceDeploymentReference{
+			Name: srcDeployment.Name,
+		}
+		set.Spec.Shards = []templatesv1.ShardSpec{
+			{
+				Name: "shard-1",
+			},
+		}
+		for _, opt := range opts {
+			opt(set)
+		}
+	})
+	test.AssertNoError(t, cl.Create(ctx, shardSet))
+	t.Cleanup(func() {
+		err := cl.Get(ctx, client.ObjectKeyFromObject(shardSet), &templatesv1.FluxShardSet{})
+		if apierrors.IsNotFound(err) {
+			return
+		}
+		test.AssertNoError(t, err)
+		deleteFluxShardSet(t, cl, shardSet)
+	})
+
+	return srcDeployment, shardSet
+}
+
+func deleteFluxShardSet(t *testing.T, cl client.Client, shardset *templatesv1.FluxShardSet) {
+	ctx := context.TODO()
+	t.Helper()
+
+	test.AssertNoError(t, cl.Get(ctx, client.ObjectKeyFromObject(shardset), shardset))
+
+	if shardset.Status.Inventory != nil {
+		for _, v := range shardset.Status.Inventory.Entries {
+			d, err := deploymentFromResourceRef(v)
+			test.AssertNoError(t, err)
+			test.AssertNoError(t, cl.Delete(ctx, d))
+		}
+	}
+
+	// The FluxShardSet isn't removed until the finalizer is removed, and the
+	// tests don't reconcile the deletion.
+	patch := client.MergeFrom(shardset.DeepCopy())
+	controllerutil.RemoveFinalizer(shardset, templatesv1.FluxShardSetFinalizer)
+	test.AssertNoError(t, cl.Patch(ctx, shardset, patch))
+
+	test.AssertNoError(t, cl.Delete(ctx, shardset))
+}
+
+func nsn(namespace, name string) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+}
+
+func deleteObject(t *testing.T, cl client.Client, obj client.Object) {
+	t.Helper()
+	test.AssertNoError(t, cl.Delete(context.TODO(), obj))
+}
+
+func TestIsImmutableFieldError(t *testing.T) {
+	immutableTests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "immutable selector",
+			err: fmt.Errorf("failed to apply Deployment: %w", apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "test",
+				field.ErrorList{field.Invalid(field.NewPath("spec", "selector"), "test", "field is immutable")})),
+			want: true,
+		},
+		{
+			name: "invalid field",
+			err: apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "test",
+				field.ErrorList{field.Invalid(field.NewPath("spec", "replicas"), -1, "must be greater than or equal to 0")}),
+			want: false,
+		},
+		{
+			name: "other errors",
+			err:  apierrors.NewNotFound(appsv1.Resource("deployments"), "test"),
+			want: false,
+		},
+	}
+
+	for _, tt := range immutableTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isImmutableFieldError(tt.err); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// selectorPatch adds a label to the selector of the shard Deployments, the
+// Deployments must be recreated to apply it.
+const selectorPatch = `
+- op: add
+  path: /spec/selector/matchLabels/tier
+  value: shards
+- op: add
+  path: /spec/template/metadata/labels/tier
+  value: shards
+`
+
+func assertRecreatePhase(t *testing.T, shardSet *templatesv1.FluxShardSet, want templatesv1.RecreatePhase) {
+	t.Helper()
+	if phase := shardSet.Status.Shards[0].RecreatePhase; phase != want {
+		t.Fatalf("got recreate phase %q, want %q", phase, want)
+	}
+}
+
+// markDeploymentAvailable updates the status of the Deployment as the
+// Deployment controller does, it doesn't run in the test environment.
+func markDeploymentAvailable(t *testing.T, cl client.Client, key client.ObjectKey) {
+	t.Helper()
+	d := &appsv1.Deployment{}
+	test.AssertNoError(t, cl.Get(context.TODO(), key, d))
+	d.Status = availableDeploymentStatus(d.Generation, 1)
+	test.AssertNoError(t, cl.Status().Update(context.TODO(), d))
+}
+
+// removeForegroundDeletion removes the finalizer of a Deployment deleted in
+// the foreground, as the garbage collector does when the pods are deleted, it
+// doesn't run in the test environment.
+func removeForegroundDeletion(t *testing.T, cl client.Client, key client.ObjectKey) {
+	t.Helper()
+	d := &appsv1.Deployment{}
+	test.AssertNoError(t, cl.Get(context.TODO(), key, d))
+	if d.GetDeletionTimestamp() == nil {
+		t.Fatalf("Deployment %s is not being deleted", key)
+	}
+	patch := client.MergeFrom(d.DeepCopy())
+	d.SetFinalizers(nil)
+	test.AssertNoError(t, cl.Patch(context.TODO(), d, patch))
 }
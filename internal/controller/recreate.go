@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+)
+
+// temporaryDeploymentSuffix is appended to the name of a shard Deployment for
+// the temporary Deployment that runs the shard while it is recreated with the
+// CreateFirst strategy.
+const temporaryDeploymentSuffix = "-recreate"
+
+// recreateDeployment replaces the existing Deployment with the generated
+// Deployment when it can't be applied because immutable fields have changed,
+// and returns the phase the recreation is at, or an empty phase when the
+// Deployment has been recreated.
+//
+// The recreation takes several reconciliations, phase is the phase recorded
+// in the status of the shard, or empty to start recreating the Deployment.
+// existing is the Deployment with the generated name, or nil if it doesn't
+// exist.
+//
+// With the DeleteFirst strategy, the existing Deployment is deleted in the
+// foreground so that the new Deployment is created when its pods have been
+// removed. With the CreateFirst strategy, a temporary Deployment runs the
+// shard until the new Deployment is available.
+func (r *FluxShardSetReconciler) recreateDeployment(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, existing, depl *appsv1.Deployment, phase templatesv1.RecreatePhase) (templatesv1.RecreatePhase, error) {
+	createFirst := fluxShardSet.Spec.RecreateStrategy == templatesv1.CreateFirstRecreateStrategy
+
+	switch phase {
+	case "":
+		if err := logResourceMessage(log.FromContext(ctx), "recreating deployment", existing); err != nil {
+			return phase, err
+		}
+		r.Eventf(fluxShardSet, corev1.EventTypeNormal, deploymentRecreatingReason,
+			"Deployment %s is recreated because immutable fields changed", client.ObjectKeyFromObject(depl))
+
+		if createFirst {
+			if err := r.applyDeployment(ctx, fluxShardSet, temporaryDeployment(depl)); err != nil {
+				return phase, fmt.Errorf("failed to create temporary Deployment: %w", err)
+			}
+			return templatesv1.RecreateStartingTemporaryPhase, nil
+		}
+
+		return templatesv1.RecreateDeletingPhase, r.deleteRecreatedDeployment(ctx, existing)
+
+	case templatesv1.RecreateStartingTemporaryPhase:
+		temporary := temporaryDeployment(depl)
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(temporary), temporary); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return phase, fmt.Errorf("failed to load temporary Deployment: %w", err)
+			}
+			if err := r.applyDeployment(ctx, fluxShardSet, temporaryDeployment(depl)); err != nil {
+				return phase, fmt.Errorf("failed to create temporary Deployment: %w", err)
+			}
+			return phase, nil
+		}
+
+		available, err := isAvailable(temporary)
+		if err != nil || !available {
+			return phase, err
+		}
+
+		return templatesv1.RecreateDeletingPhase, r.deleteRecreatedDeployment(ctx, existing)
+
+	case templatesv1.RecreateDeletingPhase:
+		// The Deployment is deleted again if it was created by someone else
+		// before the FluxShardSet was reconciled.
+		if existing != nil {
+			if existing.GetDeletionTimestamp() == nil {
+				return phase, r.deleteRecreatedDeployment(ctx, existing)
+			}
+			return phase, nil
+		}
+
+		if err := r.applyDeployment(ctx, fluxShardSet, depl); err != nil {
+			return phase, fmt.Errorf("failed to recreate Deployment: %w", err)
+		}
+		if createFirst {
+			return templatesv1.RecreateStartingPhase, nil
+		}
+
+	case templatesv1.RecreateStartingPhase:
+		if existing == nil {
+			if err := r.applyDeployment(ctx, fluxShardSet, depl); err != nil {
+				return phase, fmt.Errorf("failed to recreate Deployment: %w", err)
+			}
+			return phase, nil
+		}
+
+		available, err := isAvailable(existing)
+		if err != nil || !available {
+			return phase, err
+		}
+		if err := r.Client.Delete(ctx, temporaryDeployment(depl)); client.IgnoreNotFound(err) != nil {
+			return phase, fmt.Errorf("failed to delete temporary Deployment: %w", err)
+		}
+
+	default:
+		return phase, fmt.Errorf("unknown recreate phase %q", phase)
+	}
+
+	r.Eventf(fluxShardSet, corev1.EventTypeNormal, deploymentRecreatedReason,
+		"Deployment %s recreated because immutable fields changed", client.ObjectKeyFromObject(depl))
+
+	return "", nil
+}
+
+// deleteRecreatedDeployment deletes the Deployment that is recreated, the
+// Deployment is removed when its pods have been deleted.
+func (r *FluxShardSetReconciler) deleteRecreatedDeployment(ctx context.Context, existing *appsv1.Deployment) error {
+	if err := r.Client.Delete(ctx, existing, client.Preconditions{UID: &existing.UID}, client.PropagationPolicy(metav1.DeletePropagationForeground)); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete Deployment to recreate it: %w", err)
+	}
+
+	return nil
+}
+
+// temporaryDeployment returns a copy of the generated Deployment with the name
+// of the temporary Deployment used to recreate it.
+func temporaryDeployment(depl *appsv1.Deployment) *appsv1.Deployment {
+	temporary := depl.DeepCopy()
+	temporary.SetName(temporaryDeploymentName(depl.GetName()))
+	temporary.SetResourceVersion("")
+	temporary.SetUID("")
+
+	return temporary
+}
+
+// temporaryDeploymentName returns the name of the temporary Deployment used
+// to recreate the named Deployment.
+func temporaryDeploymentName(name string) string {
+	return name + temporaryDeploymentSuffix
+}
+
+// isAvailable returns true if kstatus considers the Deployment to be current.
+func isAvailable(deployment *appsv1.Deployment) (bool, error) {
+	health, err := deploymentHealth(deployment)
+	if err != nil {
+		return false, err
+	}
+
+	return health == status.CurrentStatus, nil
+}
+
+// recreatingShards returns the number of shards with Deployments that are
+// being recreated.
+func recreatingShards(shards []templatesv1.ShardStatus) int {
+	recreating := 0
+	for _, shard := range shards {
+		if shard.RecreatePhase != "" {
+			recreating++
+		}
+	}
+
+	return recreating
+}
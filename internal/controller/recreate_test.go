@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+)
+
+func TestTemporaryDeployment(t *testing.T) {
+	depl := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "kustomize-controller-shard-1",
+			Namespace:       "flux-system",
+			ResourceVersion: "1",
+			UID:             "test-uid",
+		},
+	}
+
+	temporary := temporaryDeployment(depl)
+
+	if temporary.Name != "kustomize-controller-shard-1-recreate" {
+		t.Errorf("got name %q, want kustomize-controller-shard-1-recreate", temporary.Name)
+	}
+	if temporary.ResourceVersion != "" || temporary.UID != "" {
+		t.Errorf("got resource version %q and UID %q, want none", temporary.ResourceVersion, temporary.UID)
+	}
+	if depl.Name != "kustomize-controller-shard-1" {
+		t.Errorf("the generated Deployment was renamed to %q", depl.Name)
+	}
+}
+
+func TestRecreatingShards(t *testing.T) {
+	shards := []templatesv1.ShardStatus{
+		{Name: "shard-1", Phase: templatesv1.ShardActivePhase},
+		{Name: "shard-2", Phase: templatesv1.ShardActivePhase, RecreatePhase: templatesv1.RecreateDeletingPhase},
+	}
+
+	if n := recreatingShards(shards); n != 1 {
+		t.Fatalf("got %d recreating shards, want 1", n)
+	}
+}
@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/runtime/conditions"
+	"github.com/gitops-tools/pkg/sets"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/assignment"
+	deploys "github.com/weaveworks/flux-shard-controller/internal/deploys"
+)
+
+// reconcileRollout records the progress of rolling out the generated
+// Deployments with the rollout strategy of the FluxShardSet, and returns the
+// names of the shards with changed Deployments that must not be updated yet.
+//
+// refs holds the resource refs of the generated Deployments and current holds
+// the existing Deployments, or nil if the Deployment doesn't exist, in the
+// order of the shards. Deployments that are created, or belong to suspended
+// shards or shards in maintenance, are not part of the rollout.
+func (r *FluxShardSetReconciler) reconcileRollout(ctx context.Context, fluxShardSet *templatesv1.FluxShardSet, shards []templatesv1.ShardSpec, refs []templatesv1.ResourceRef, current []*appsv1.Deployment, existingInventory map[string]templatesv1.ResourceRef) (sets.Set[string], error) {
+	strategy := fluxShardSet.Spec.RolloutStrategy
+	if strategy == nil {
+		fluxShardSet.Status.Rollout = nil
+		conditions.Delete(fluxShardSet, templatesv1.RolloutPausedCondition)
+		return sets.New[string](), nil
+	}
+
+	kinds, err := assignment.Kinds(fluxShardSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll out Deployments: %w", err)
+	}
+
+	previous := fluxShardSet.Status.Rollout
+	revision := rolloutRevision(shards, refs)
+	rollout := &templatesv1.RolloutStatus{Revision: revision}
+	if previous != nil && previous.Revision == revision {
+		rollout.UpdatedShards = append(rollout.UpdatedShards, previous.UpdatedShards...)
+	}
+	updated := sets.New(rollout.UpdatedShards...)
+
+	pending := []string{}
+	waiting := sets.New[string]()
+	unavailable := 0
+	failures := []string{}
+	for i, shard := range shards {
+		existing := current[i]
+		ref, inInventory := existingInventory[refs[i].ID]
+		if existing == nil || !inInventory || shard.Suspend || shard.Maintenance {
+			continue
+		}
+
+		if ref.Checksum != refs[i].Checksum || existing.GetAnnotations()[deploys.ChecksumAnnotation] != refs[i].Checksum {
+			pending = append(pending, shard.Name)
+			waiting.Insert(shard.Name)
+			if updated.Has(shard.Name) {
+				unavailable++
+			}
+			continue
+		}
+
+		rolledOut, failure, err := r.rolloutShard(ctx, kinds, shard.Name, existing, revision, updated.Has(shard.Name))
+		if err != nil {
+			return nil, err
+		}
+		if failure != "" {
+			failures = append(failures, failure)
+		}
+		if !rolledOut {
+			waiting.Insert(shard.Name)
+			unavailable++
+		}
+	}
+
+	canaryWaiting := updated.Has(strategy.CanaryShard) && waiting.Has(strategy.CanaryShard)
+	held := planRollout(strategy, rollout, pending, unavailable, canaryWaiting, len(failures) > 0)
+
+	// Shards are no longer recorded as updated when they have rolled out, or
+	// are no longer part of the rollout.
+	updatedShards := []string{}
+	for _, name := range rollout.UpdatedShards {
+		if waiting.Has(name) {
+			updatedShards = append(updatedShards, name)
+		}
+	}
+	rollout.UpdatedShards = updatedShards
+	rollout.PendingShards = held
+	fluxShardSet.Status.Rollout = rollout
+
+	if len(failures) > 0 && !conditions.IsTrue(fluxShardSet, templatesv1.RolloutPausedCondition) {
+		r.Eventf(fluxShardSet, corev1.EventTypeWarning, rolloutFailedReason,
+			"rollout paused, %d shard(s) failed to roll out: %s", len(failures), strings.Join(failures, "; "))
+	}
+	templatesv1.SetRolloutPaused(fluxShardSet, failures)
+
+	if rollingOutShards(previous) > 0 && rollingOutShards(rollout) == 0 {
+		r.Eventf(fluxShardSet, corev1.EventTypeNormal, rolloutCompletedReason,
+			"rollout of %s completed", revision)
+	}
+
+	return sets.New(held...), nil
+}
+
+// rolloutShard returns true if the shard's Deployment is available and, if the
+// shard was updated in the rollout, the Flux objects assigned to the shard
+// of the kinds have reconciled successfully since.
+//
+// The reconciliation of the objects is requested when the Deployment becomes
+// available. A description of the failure is returned if the Deployment or
+// the objects of an updated shard failed.
+func (r *FluxShardSetReconciler) rolloutShard(ctx context.Context, kinds []string, shard string, deployment *appsv1.Deployment, revision string, updated bool) (bool, string, error) {
+	health, err := deploymentHealth(deployment)
+	if err != nil {
+		return false, "", err
+	}
+
+	if health == status.FailedStatus && updated {
+		return false, fmt.Sprintf("Deployment %s for shard %s failed", client.ObjectKeyFromObject(deployment), shard), nil
+	}
+	if health != status.CurrentStatus {
+		return false, "", nil
+	}
+	if !updated {
+		return true, "", nil
+	}
+
+	requestedAt := rolloutRequest(shard, revision)
+	if err := assignment.RequestReconciliation(ctx, r.Client, kinds, shard, requestedAt); err != nil {
+		return false, "", err
+	}
+	pending, failed, err := assignment.Reconciled(ctx, r.Client, kinds, shard, requestedAt)
+	if err != nil {
+		return false, "", err
+	}
+	if failed > 0 {
+		return false, fmt.Sprintf("%d object(s) on shard %s failed to reconcile", failed, shard), nil
+	}
+
+	return pending == 0, "", nil
+}
+
+// planRollout returns the shards with changed Deployments that must not be
+// updated yet, and records the shards that can be updated in the rollout.
+//
+// pending holds the shards with changed Deployments in the order of the
+// shards, and unavailable is the number of other shards that are updating or
+// not available. Shards that were recorded as updated but still have changed
+// Deployments are updated again.
+//
+// If there is a canary shard with a changed Deployment it is updated first,
+// and no other shards are updated while it is waiting to roll out. No shards
+// are updated while the rollout is paused.
+func planRollout(strategy *templatesv1.RolloutStrategy, rollout *templatesv1.RolloutStatus, pending []string, unavailable int, canaryWaiting, paused bool) []string {
+	maxUnavailable := strategy.MaxUnavailable
+	if maxUnavailable < 1 {
+		maxUnavailable = 1
+	}
+	budget := maxUnavailable - unavailable
+
+	ordered := pending
+	if strategy.CanaryShard != "" && contains(pending, strategy.CanaryShard) {
+		ordered = []string{strategy.CanaryShard}
+		for _, name := range pending {
+			if name != strategy.CanaryShard {
+				ordered = append(ordered, name)
+			}
+		}
+	}
+
+	updated := sets.New(rollout.UpdatedShards...)
+	held := []string{}
+	for _, name := range ordered {
+		switch {
+		case updated.Has(name):
+		case paused || budget <= 0 || (canaryWaiting && name != strategy.CanaryShard):
+			held = append(held, name)
+		default:
+			budget--
+			rollout.UpdatedShards = append(rollout.UpdatedShards, name)
+			updated.Insert(name)
+		}
+
+		if name == strategy.CanaryShard {
+			canaryWaiting = true
+		}
+	}
+
+	return held
+}
+
+// rolloutRevision returns the revision of the generated Deployments for the
+// shards that are rolled out.
+func rolloutRevision(shards []templatesv1.ShardSpec, refs []templatesv1.ResourceRef) string {
+	h := sha256.New()
+	for i, shard := range shards {
+		if shard.Suspend || shard.Maintenance {
+			continue
+		}
+		fmt.Fprintf(h, "%s=%s\n", shard.Name, refs[i].Checksum)
+	}
+
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+// rolloutRequest returns the value used to request the reconciliation of the
+// Flux objects of a shard that was updated to the revision.
+func rolloutRequest(shard, revision string) string {
+	return fmt.Sprintf("rollout-%s-%s", shard, strings.TrimPrefix(revision, "sha256:")[:12])
+}
+
+// rollingOutShards returns the number of shards that are updated or waiting to
+// be updated in the rollout.
+func rollingOutShards(rollout *templatesv1.RolloutStatus) int {
+	if rollout == nil {
+		return 0
+	}
+
+	return len(rollout.UpdatedShards) + len(rollout.PendingShards)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+)
+
+func TestPlanRollout(t *testing.T) {
+	tests := []struct {
+		name          string
+		strategy      templatesv1.RolloutStrategy
+		updated       []string
+		pending       []string
+		unavailable   int
+		canaryWaiting bool
+		paused        bool
+		wantUpdated   []string
+		wantHeld      []string
+	}{
+		{
+			name:        "updates one shard at a time by default",
+			pending:     []string{"shard-a", "shard-b", "shard-c"},
+			wantUpdated: []string{"shard-a"},
+			wantHeld:    []string{"shard-b", "shard-c"},
+		},
+		{
+			name:        "updates up to max unavailable shards",
+			strategy:    templatesv1.RolloutStrategy{MaxUnavailable: 2},
+			pending:     []string{"shard-a", "shard-b", "shard-c"},
+			wantUpdated: []string{"shard-a", "shard-b"},
+			wantHeld:    []string{"shard-c"},
+		},
+		{
+			name:        "unavailable shards count towards max unavailable",
+			strategy:    templatesv1.RolloutStrategy{MaxUnavailable: 2},
+			updated:     []string{"shard-a"},
+			pending:     []string{"shard-b", "shard-c"},
+			unavailable: 1,
+			wantUpdated: []string{"shard-a", "shard-b"},
+			wantHeld:    []string{"shard-c"},
+		},
+		{
+			name:        "updates the canary shard first",
+			strategy:    templatesv1.RolloutStrategy{CanaryShard: "shard-b", MaxUnavailable: 2},
+			pending:     []string{"shard-a", "shard-b", "shard-c"},
+			wantUpdated: []string{"shard-b"},
+			wantHeld:    []string{"shard-a", "shard-c"},
+		},
+		{
+			name:          "waits for the canary shard to roll out",
+			strategy:      templatesv1.RolloutStrategy{CanaryShard: "shard-b", MaxUnavailable: 2},
+			updated:       []string{"shard-b"},
+			pending:       []string{"shard-a", "shard-c"},
+			unavailable:   1,
+			canaryWaiting: true,
+			wantUpdated:   []string{"shard-b"},
+			wantHeld:      []string{"shard-a", "shard-c"},
+		},
+		{
+			name:        "updated shards with changes are updated again",
+			updated:     []string{"shard-a"},
+			pending:     []string{"shard-a", "shard-b"},
+			unavailable: 1,
+			wantUpdated: []string{"shard-a"},
+			wantHeld:    []string{"shard-b"},
+		},
+		{
+			name:        "paused rollouts don't update shards",
+			updated:     []string{"shard-a"},
+			pending:     []string{"shard-b"},
+			paused:      true,
+			wantUpdated: []string{"shard-a"},
+			wantHeld:    []string{"shard-b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rollout := &templatesv1.RolloutStatus{UpdatedShards: tt.updated}
+
+			held := planRollout(&tt.strategy, rollout, tt.pending, tt.unavailable, tt.canaryWaiting, tt.paused)
+
+			if diff := cmp.Diff(tt.wantHeld, held); diff != "" {
+				t.Errorf("failed to hold shards:\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantUpdated, rollout.UpdatedShards); diff != "" {
+				t.Errorf("failed to update shards:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRolloutRevision(t *testing.T) {
+	shards := []templatesv1.ShardSpec{{Name: "shard-a"}, {Name: "shard-b"}}
+	refs := []templatesv1.ResourceRef{{Checksum: "sha256:1"}, {Checksum: "sha256:2"}}
+
+	revision := rolloutRevision(shards, refs)
+
+	shards[1].Maintenance = true
+	if rolloutRevision(shards, refs) == revision {
+		t.Errorf("revision did not change when a shard is in maintenance")
+	}
+
+	refs[1].Checksum = "sha256:3"
+	shards[1].Maintenance = false
+	if rolloutRevision(shards, refs) == revision {
+		t.Errorf("revision did not change when a checksum changed")
+	}
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"fmt"
+
+	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	deploys "github.com/weaveworks/flux-shard-controller/internal/deploys"
+)
+
+// newShardStatus returns the status for a shard from the state of its
+// Deployment.
+func newShardStatus(name string, assignedObjects int, deployment *appsv1.Deployment) (templatesv1.ShardStatus, error) {
+	shardStatus := templatesv1.ShardStatus{
+		Name:            name,
+		AssignedObjects: assignedObjects,
+	}
+	if deployment == nil {
+		return shardStatus, nil
+	}
+
+	shardStatus.DeploymentRef = &fluxMeta.NamespacedObjectReference{
+		Name:      deployment.GetName(),
+		Namespace: deployment.GetNamespace(),
+	}
+	if deployment.Spec.Replicas != nil {
+		shardStatus.Replicas = *deployment.Spec.Replicas
+	}
+	shardStatus.RestartedAt = deployment.Spec.Template.Annotations[templatesv1.RestartedAtAnnotation]
+	shardStatus.ReadyReplicas = deployment.Status.ReadyReplicas
+	shardStatus.AvailableReplicas = deployment.Status.AvailableReplicas
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == deploys.ManagerContainerName {
+			shardStatus.Image = container.Image
+		}
+	}
+
+	health, err := deploymentHealth(deployment)
+	if err != nil {
+		return shardStatus, err
+	}
+	shardStatus.Health = health.String()
+
+	return shardStatus, nil
+}
+
+func deploymentHealth(deployment *appsv1.Deployment) (status.Status, error) {
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
+	if err != nil {
+		return status.UnknownStatus, fmt.Errorf("failed to convert Deployment %s/%s: %w", deployment.GetNamespace(), deployment.GetName(), err)
+	}
+	u := &unstructured.Unstructured{Object: raw}
+	u.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+
+	result, err := status.Compute(u)
+	if err != nil {
+		return status.UnknownStatus, fmt.Errorf("failed to compute health of Deployment %s/%s: %w", deployment.GetNamespace(), deployment.GetName(), err)
+	}
+
+	return result.Status, nil
+}
+
+// unavailableShards returns the number of shards with Deployments that are not
+// available.
+//
+// Draining shards are not counted, and neither are suspended shards because
+// their Deployments are not reconciled and may not exist.
+func unavailableShards(shards []templatesv1.ShardStatus) int {
+	unavailable := 0
+	for _, shard := range shards {
+		if shard.Phase == templatesv1.ShardDrainingPhase || shard.Phase == templatesv1.ShardSuspendedPhase {
+			continue
+		}
+		if shard.Health != status.CurrentStatus.String() {
+			unavailable++
+		}
+	}
+
+	return unavailable
+}
+
+// drainingShards returns the number of shards that are draining.
+func drainingShards(shards []templatesv1.ShardStatus) int {
+	draining := 0
+	for _, shard := range shards {
+		if shard.Phase == templatesv1.ShardDrainingPhase {
+			draining++
+		}
+	}
+
+	return draining
+}
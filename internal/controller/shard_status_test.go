@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"testing"
+
+	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestNewShardStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		deployment *appsv1.Deployment
+		want       templatesv1.ShardStatus
+	}{
+		{
+			name: "available deployment",
+			deployment: test.MakeTestDeployment(nsn("flux-system", "kustomize-controller-shard-1"), func(d *appsv1.Deployment) {
+				d.Generation = 2
+				d.Status = availableDeploymentStatus(2, 1)
+				d.Spec.Template.Annotations = map[string]string{templatesv1.RestartedAtAnnotation: "2023-06-01T00:00:00Z"}
+			}),
+			want: templatesv1.ShardStatus{
+				Name:              "shard-1",
+				AssignedObjects:   5,
+				DeploymentRef:     &fluxMeta.NamespacedObjectReference{Name: "kustomize-controller-shard-1", Namespace: "flux-system"},
+				Replicas:          1,
+				ReadyReplicas:     1,
+				AvailableReplicas: 1,
+				Image:             "ghcr.io/fluxcd/kustomize-controller:v0.35.1",
+				Health:            "Current",
+				RestartedAt:       "2023-06-01T00:00:00Z",
+			},
+		},
+		{
+			name: "deployment rolling out",
+			deployment: test.MakeTestDeployment(nsn("flux-system", "kustomize-controller-shard-1"), func(d *appsv1.Deployment) {
+				d.Generation = 3
+				d.Status = availableDeploymentStatus(2, 1)
+			}),
+			want: templatesv1.ShardStatus{
+				Name:              "shard-1",
+				AssignedObjects:   5,
+				DeploymentRef:     &fluxMeta.NamespacedObjectReference{Name: "kustomize-controller-shard-1", Namespace: "flux-system"},
+				Replicas:          1,
+				ReadyReplicas:     1,
+				AvailableReplicas: 1,
+				Image:             "ghcr.io/fluxcd/kustomize-controller:v0.35.1",
+				Health:            "InProgress",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shardStatus, err := newShardStatus("shard-1", 5, tt.deployment)
+			test.AssertNoError(t, err)
+
+			if diff := cmp.Diff(tt.want, shardStatus); diff != "" {
+				t.Fatalf("failed to generate shard status:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnavailableShards(t *testing.T) {
+	shards := []templatesv1.ShardStatus{
+		{Name: "shard-1", Health: "Current"},
+		{Name: "shard-2", Health: "InProgress"},
+		{Name: "shard-3", Health: "Failed"},
+		{Name: "shard-4", Health: "InProgress", Phase: templatesv1.ShardDrainingPhase},
+		{Name: "shard-5", Phase: templatesv1.ShardSuspendedPhase},
+	}
+
+	if n := unavailableShards(shards); n != 2 {
+		t.Fatalf("got %d unavailable shards, want 2", n)
+	}
+}
+
+func TestDrainingShards(t *testing.T) {
+	shards := []templatesv1.ShardStatus{
+		{Name: "shard-1", Phase: templatesv1.ShardActivePhase},
+		{Name: "shard-2", Phase: templatesv1.ShardDrainingPhase},
+	}
+
+	if n := drainingShards(shards); n != 1 {
+		t.Fatalf("got %d draining shards, want 1", n)
+	}
+}
+
+func availableDeploymentStatus(generation int64, replicas int32) appsv1.DeploymentStatus {
+	return appsv1.DeploymentStatus{
+		ObservedGeneration: generation,
+		Replicas:           replicas,
+		UpdatedReplicas:    replicas,
+		ReadyReplicas:      replicas,
+		AvailableReplicas:  replicas,
+		Conditions: []appsv1.DeploymentCondition{
+			{
+				Type:   appsv1.DeploymentAvailable,
+				Status: corev1.ConditionTrue,
+			},
+			{
+				Type:   appsv1.DeploymentProgressing,
+				Status: corev1.ConditionTrue,
+				Reason: "NewReplicaSetAvailable",
+			},
+		},
+	}
+}
@@ -0,0 +1,35 @@
+package deploys
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// ChecksumAnnotation is the annotation on generated Deployments that records
+// the checksum of the generated Deployment.
+const ChecksumAnnotation = "templates.weave.works/checksum"
+
+// SetChecksum calculates the checksum of the generated Deployment and records
+// it in the ChecksumAnnotation.
+//
+// The checksum is returned so that it can be compared with the checksum of the
+// last applied Deployment.
+func SetChecksum(depl *appsv1.Deployment) (string, error) {
+	if depl.Annotations == nil {
+		depl.Annotations = map[string]string{}
+	}
+	// Any existing checksum is not included in the checksum.
+	delete(depl.Annotations, ChecksumAnnotation)
+
+	b, err := json.Marshal(depl)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal Deployment %s/%s: %w", depl.GetNamespace(), depl.GetName(), err)
+	}
+	checksum := fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+	depl.Annotations[ChecksumAnnotation] = checksum
+
+	return checksum, nil
+}
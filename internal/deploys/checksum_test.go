@@ -0,0 +1,36 @@
+package deploys
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestSetChecksum(t *testing.T) {
+	depl := newTestDeployment()
+
+	checksum, err := SetChecksum(depl)
+	test.AssertNoError(t, err)
+
+	if v := depl.Annotations[ChecksumAnnotation]; v != checksum {
+		t.Fatalf("got checksum annotation %q, want %q", v, checksum)
+	}
+
+	// Setting the checksum again gives the same checksum.
+	again, err := SetChecksum(depl)
+	test.AssertNoError(t, err)
+	if again != checksum {
+		t.Fatalf("got checksum %q, want %q", again, checksum)
+	}
+
+	changed := newTestDeployment(func(d *appsv1.Deployment) {
+		d.Spec.Template.Spec.Containers[0].Image = "ghcr.io/fluxcd/kustomize-controller:v1.0.0"
+	})
+	changedChecksum, err := SetChecksum(changed)
+	test.AssertNoError(t, err)
+	if changedChecksum == checksum {
+		t.Fatalf("got the same checksum %q for a changed Deployment", checksum)
+	}
+}
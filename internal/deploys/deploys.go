@@ -6,14 +6,24 @@ import (
 
 	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
-	ignoreShardsSelector    = "!sharding.fluxcd.io/key"
-	ignoreShardsSelectorArg = "--watch-label-selector=" + ignoreShardsSelector
-	shardsSelector          = "sharding.fluxcd.io/key"
+	// ShardKeyLabel is the label used by Flux controllers to select the
+	// objects they reconcile when sharding.
+	ShardKeyLabel = "sharding.fluxcd.io/key"
+
+	// ManagerContainerName is the name of the Flux controller container in
+	// the Deployments.
+	ManagerContainerName = "manager"
+
+	ignoreShardsSelector    = "!" + ShardKeyLabel
+	watchLabelSelectorFlag  = "--watch-label-selector"
+	ignoreShardsSelectorArg = watchLabelSelectorFlag + "=" + ignoreShardsSelector
 )
 
 // newDeploymentFromDeployment takes a Deployment loaded from the Cluster and
@@ -37,6 +47,9 @@ func newDeploymentFromDeployment(src appsv1.Deployment) *appsv1.Deployment {
 	depl.Generation = 0
 	depl.ResourceVersion = ""
 	depl.UID = ""
+	// The generated Deployments are applied with server-side apply which
+	// doesn't accept managed fields.
+	depl.ManagedFields = nil
 	depl.Status = appsv1.DeploymentStatus{}
 
 	return depl
@@ -49,19 +62,14 @@ func updateNewDeployment(depl *appsv1.Deployment, shardSetName, shardName, newDe
 		depl.ObjectMeta.Labels = map[string]string{}
 	}
 
-	shardLabels := map[string]string{
-		"app.kubernetes.io/managed-by":    "flux-shard-controller",
-		"templates.weave.works/shard-set": shardSetName,
-		"templates.weave.works/shard":     shardName,
-		"sharding.fluxcd.io/role":         "shard",
-	}
+	shardLabels := newShardLabels(shardSetName, shardName)
 
 	depl.ObjectMeta.Labels = merge(
 		shardLabels,
 		depl.ObjectMeta.Labels,
 	)
 	// generate selector args string
-	selectorArgs, err := generateSelectorStr("--watch-label-selector", shardsSelector, metav1.LabelSelectorOpIn, []string{shardName})
+	selectorArgs, err := generateSelectorStr(watchLabelSelectorFlag, ShardKeyLabel, metav1.LabelSelectorOpIn, []string{shardName})
 	if err != nil {
 		return err
 	}
@@ -70,7 +78,7 @@ func updateNewDeployment(depl *appsv1.Deployment, shardSetName, shardName, newDe
 		if container.Args == nil {
 			container.Args = []string{}
 		}
-		if container.Name == "manager" {
+		if container.Name == ManagerContainerName {
 			replaceArg(container.Args, ignoreShardsSelectorArg, selectorArgs)
 		}
 
@@ -93,6 +101,17 @@ func updateNewDeployment(depl *appsv1.Deployment, shardSetName, shardName, newDe
 	return nil
 }
 
+// newShardLabels returns the labels that identify the Deployment, and the
+// pods, of a shard.
+func newShardLabels(shardSetName, shardName string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by":    "flux-shard-controller",
+		"templates.weave.works/shard-set": shardSetName,
+		"templates.weave.works/shard":     shardName,
+		"sharding.fluxcd.io/role":         "shard",
+	}
+}
+
 // return a copy off the "dest" map, with the elements of the "src" map applied
 // over the top.
 func merge[K comparable, V any](src, dest map[K]V) map[K]V {
@@ -108,13 +127,13 @@ func merge[K comparable, V any](src, dest map[K]V) map[K]V {
 }
 
 // GenerateDeployments creates list of new deployments to process the set of
-// shards declared in the ShardSet.
-func GenerateDeployments(fluxShardSet *v1alpha1.FluxShardSet, src *appsv1.Deployment) ([]*appsv1.Deployment, error) {
+// shards for the ShardSet.
+func GenerateDeployments(fluxShardSet *v1alpha1.FluxShardSet, src *appsv1.Deployment, shards []v1alpha1.ShardSpec) ([]*appsv1.Deployment, error) {
 	if !deploymentIgnoresShardLabels(src) {
 		return nil, fmt.Errorf("deployment %s is not configured to ignore sharding", client.ObjectKeyFromObject(src))
 	}
 	generatedDeployments := []*appsv1.Deployment{}
-	for _, shard := range fluxShardSet.Spec.Shards {
+	for _, shard := range shards {
 		deployment := newDeploymentFromDeployment(*src)
 		newDeploymentName := fmt.Sprintf("%s-%s", src.ObjectMeta.Name, shard.Name)
 		err := updateNewDeployment(deployment, fluxShardSet.Name, shard.Name, newDeploymentName)
@@ -122,12 +141,163 @@ func GenerateDeployments(fluxShardSet *v1alpha1.FluxShardSet, src *appsv1.Deploy
 			return nil, err
 		}
 
+		if err := applyOverrides(deployment, shard.Overrides); err != nil {
+			return nil, fmt.Errorf("failed to apply overrides for shard %s: %w", shard.Name, err)
+		}
+
+		patches := append(append([]v1alpha1.Patch{}, fluxShardSet.Spec.Patches...), shard.Patches...)
+		deployment, err = applyPatches(deployment, patches, patchParams{ShardName: shard.Name, ShardSetName: fluxShardSet.Name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply patches for shard %s: %w", shard.Name, err)
+		}
+
+		// With the External policy, the replicas copied from the source
+		// Deployment are not applied, so that the shard Deployments can be
+		// scaled by other field managers e.g. a HorizontalPodAutoscaler.
+		// Replicas set by the overrides or patches are applied.
+		if fluxShardSet.Spec.ReplicasPolicy == v1alpha1.ExternalReplicasPolicy &&
+			(shard.Overrides == nil || shard.Overrides.Replicas == nil) && equalReplicas(deployment.Spec.Replicas, src.Spec.Replicas) {
+			deployment.Spec.Replicas = nil
+		}
+
+		// Shards in maintenance are scaled to zero whatever the replicas in
+		// the overrides and patches.
+		if shard.Maintenance {
+			deployment.Spec.Replicas = pointer.Int32(0)
+		}
+
+		if restartedAt := shardRestartedAt(fluxShardSet, shard.Name); restartedAt != "" {
+			if deployment.Spec.Template.Annotations == nil {
+				deployment.Spec.Template.Annotations = map[string]string{}
+			}
+			deployment.Spec.Template.Annotations[v1alpha1.RestartedAtAnnotation] = restartedAt
+		}
+
 		generatedDeployments = append(generatedDeployments, deployment)
 	}
 
 	return generatedDeployments, nil
 }
 
+func equalReplicas(a, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+// shardRestartedAt returns the restart requested for the shard by the
+// FluxShardSet annotations.
+//
+// Shards that are not selected by the restart keep the restart recorded in
+// their status, so that they are not restarted when the annotation changes.
+func shardRestartedAt(fluxShardSet *v1alpha1.FluxShardSet, shardName string) string {
+	annotations := fluxShardSet.GetAnnotations()
+	if restartedAt, ok := annotations[v1alpha1.RestartedAtAnnotation]; ok && restartSelects(annotations[v1alpha1.RestartShardsAnnotation], shardName) {
+		return restartedAt
+	}
+
+	for _, shard := range fluxShardSet.Status.Shards {
+		if shard.Name == shardName {
+			return shard.RestartedAt
+		}
+	}
+
+	return ""
+}
+
+// restartSelects returns true if the shard is in the comma separated list of
+// shard names, or the list is empty.
+func restartSelects(shardNames, shardName string) bool {
+	if strings.TrimSpace(shardNames) == "" {
+		return true
+	}
+
+	for _, name := range strings.Split(shardNames, ",") {
+		if strings.TrimSpace(name) == shardName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyOverrides applies the per-shard overrides to the manager container of
+// the generated Deployment.
+func applyOverrides(depl *appsv1.Deployment, overrides *v1alpha1.ShardOverrides) error {
+	if overrides == nil {
+		return nil
+	}
+
+	if overrides.Replicas != nil {
+		depl.Spec.Replicas = pointer.Int32(*overrides.Replicas)
+	}
+
+	for i := range depl.Spec.Template.Spec.Containers {
+		container := &depl.Spec.Template.Spec.Containers[i]
+		if container.Name != ManagerContainerName {
+			continue
+		}
+
+		if overrides.Resources != nil {
+			if len(overrides.Resources.Limits) > 0 {
+				container.Resources.Limits = merge(overrides.Resources.Limits, container.Resources.Limits)
+			}
+			if len(overrides.Resources.Requests) > 0 {
+				container.Resources.Requests = merge(overrides.Resources.Requests, container.Resources.Requests)
+			}
+		}
+
+		for _, arg := range overrides.Args {
+			if argFlag(arg) == watchLabelSelectorFlag {
+				return fmt.Errorf("the %s arg can't be overridden", watchLabelSelectorFlag)
+			}
+			container.Args = setArg(container.Args, arg)
+		}
+
+		for _, env := range overrides.Env {
+			container.Env = setEnv(container.Env, env)
+		}
+	}
+
+	return nil
+}
+
+// setArg replaces the arg for the same flag as newArg if it exists, or appends
+// newArg to the args.
+func setArg(args []string, newArg string) []string {
+	for i := range args {
+		if argFlag(args[i]) == argFlag(newArg) {
+			args[i] = newArg
+			return args
+		}
+	}
+
+	return append(args, newArg)
+}
+
+// setEnv replaces the variable with the same name as newEnv if it exists, or
+// appends newEnv to the environment.
+func setEnv(env []corev1.EnvVar, newEnv corev1.EnvVar) []corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == newEnv.Name {
+			env[i] = newEnv
+			return env
+		}
+	}
+
+	return append(env, newEnv)
+}
+
+// argFlag returns the flag part of an arg e.g. "--concurrent" for
+// "--concurrent=10".
+func argFlag(arg string) string {
+	flag, _, _ := strings.Cut(arg, "=")
+
+	return flag
+}
+
 func deploymentIgnoresShardLabels(deploy *appsv1.Deployment) bool {
 	for i := range deploy.Spec.Template.Spec.Containers {
 		container := deploy.Spec.Template.Spec.Containers[i]
@@ -7,6 +7,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/utils/pointer"
@@ -263,7 +264,7 @@ func TestGenerateDeployments(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			generatedDeps, err := GenerateDeployments(tt.fluxShardSet, tt.src)
+			generatedDeps, err := GenerateDeployments(tt.fluxShardSet, tt.src, tt.fluxShardSet.Spec.Shards)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -275,6 +276,355 @@ func TestGenerateDeployments(t *testing.T) {
 	}
 }
 
+func TestGenerateDeployments_overrides(t *testing.T) {
+	srcDeployment := func(opts ...func(*appsv1.Deployment)) *appsv1.Deployment {
+		return newTestDeployment(append([]func(*appsv1.Deployment){func(d *appsv1.Deployment) {
+			d.Spec.Template.Spec.Containers[0].Args = []string{
+				"--concurrent=4",
+				"--watch-label-selector=!sharding.fluxcd.io/key",
+			}
+			d.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+				{Name: "RUNTIME_NAMESPACE", Value: "flux-system"},
+			}
+			d.Spec.Template.Spec.Containers[0].Resources = corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					"cpu":    resource.MustParse("1"),
+					"memory": resource.MustParse("1Gi"),
+				},
+				Requests: corev1.ResourceList{
+					"cpu":    resource.MustParse("100m"),
+					"memory": resource.MustParse("64Mi"),
+				},
+			}
+		}}, opts...)...)
+	}
+
+	tests := []struct {
+		name      string
+		overrides *shardv1.ShardOverrides
+		want      func(*appsv1.Deployment)
+	}{
+		{
+			name:      "no overrides",
+			overrides: nil,
+			want:      func(d *appsv1.Deployment) {},
+		},
+		{
+			name: "overriding replicas",
+			overrides: &shardv1.ShardOverrides{
+				Replicas: pointer.Int32(3),
+			},
+			want: func(d *appsv1.Deployment) {
+				d.Spec.Replicas = pointer.Int32(3)
+			},
+		},
+		{
+			name: "overriding resources replaces matching limits and requests",
+			overrides: &shardv1.ShardOverrides{
+				Resources: &corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{
+						"memory": resource.MustParse("3Gi"),
+					},
+					Requests: corev1.ResourceList{
+						"memory": resource.MustParse("192Mi"),
+					},
+				},
+			},
+			want: func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Resources = corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{
+						"cpu":    resource.MustParse("1"),
+						"memory": resource.MustParse("3Gi"),
+					},
+					Requests: corev1.ResourceList{
+						"cpu":    resource.MustParse("100m"),
+						"memory": resource.MustParse("192Mi"),
+					},
+				}
+			},
+		},
+		{
+			name: "overriding args replaces args for the same flag and appends new args",
+			overrides: &shardv1.ShardOverrides{
+				Args: []string{
+					"--concurrent=20",
+					"--requeue-dependency=5s",
+				},
+			},
+			want: func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Args = []string{
+					"--concurrent=20",
+					"--watch-label-selector=sharding.fluxcd.io/key in (shard-1)",
+					"--requeue-dependency=5s",
+				}
+			},
+		},
+		{
+			name: "overriding env replaces variables with the same name and appends new variables",
+			overrides: &shardv1.ShardOverrides{
+				Env: []corev1.EnvVar{
+					{Name: "RUNTIME_NAMESPACE", Value: "tenants"},
+					{Name: "GOMAXPROCS", Value: "2"},
+				},
+			},
+			want: func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+					{Name: "RUNTIME_NAMESPACE", Value: "tenants"},
+					{Name: "GOMAXPROCS", Value: "2"},
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fluxShardSet := &shardv1.FluxShardSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-shard-set",
+				},
+				Spec: shardv1.FluxShardSetSpec{
+					SourceDeploymentRef: shardv1.SourceDeploymentReference{
+						Name: testControllerName,
+					},
+					Shards: []shardv1.ShardSpec{
+						{
+							Name:      "shard-1",
+							Overrides: tt.overrides,
+						},
+					},
+				},
+			}
+
+			generatedDeps, err := GenerateDeployments(fluxShardSet, srcDeployment(), fluxShardSet.Spec.Shards)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := srcDeployment(func(d *appsv1.Deployment) {
+				d.Annotations = map[string]string{}
+				d.ObjectMeta.Labels = test.ShardLabels("shard-1")
+				d.ObjectMeta.Name = "kustomize-controller-shard-1"
+				d.Spec.Template.Spec.Containers[0].Args = []string{
+					"--concurrent=4",
+					"--watch-label-selector=sharding.fluxcd.io/key in (shard-1)",
+				}
+				d.Spec.Selector = &metav1.LabelSelector{
+					MatchLabels: test.ShardLabels("shard-1", map[string]string{
+						"app": "kustomize-controller",
+					}),
+				}
+				d.Spec.Template.ObjectMeta.Labels = test.ShardLabels("shard-1", map[string]string{
+					"app": "kustomize-controller",
+				})
+			}, tt.want)
+
+			if diff := cmp.Diff([]*appsv1.Deployment{want}, generatedDeps); diff != "" {
+				t.Fatalf("generated deployments dont match wanted: \n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateDeployments_overridesDontChangeSource(t *testing.T) {
+	src := newTestDeployment(func(d *appsv1.Deployment) {
+		d.Spec.Template.Spec.Containers[0].Args = []string{
+			"--concurrent=4",
+			"--watch-label-selector=!sharding.fluxcd.io/key",
+		}
+	})
+	fluxShardSet := &shardv1.FluxShardSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-shard-set",
+		},
+		Spec: shardv1.FluxShardSetSpec{
+			Shards: []shardv1.ShardSpec{
+				{
+					Name: "shard-1",
+					Overrides: &shardv1.ShardOverrides{
+						Args: []string{"--concurrent=20"},
+					},
+				},
+				{
+					Name: "shard-2",
+				},
+			},
+		},
+	}
+
+	generatedDeps, err := GenerateDeployments(fluxShardSet, src, fluxShardSet.Spec.Shards)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"--concurrent=4",
+		"--watch-label-selector=sharding.fluxcd.io/key in (shard-2)",
+	}
+	if diff := cmp.Diff(want, generatedDeps[1].Spec.Template.Spec.Containers[0].Args); diff != "" {
+		t.Fatalf("overrides for shard-1 leaked into shard-2:\n%s", diff)
+	}
+
+	wantSrc := []string{
+		"--concurrent=4",
+		"--watch-label-selector=!sharding.fluxcd.io/key",
+	}
+	if diff := cmp.Diff(wantSrc, src.Spec.Template.Spec.Containers[0].Args); diff != "" {
+		t.Fatalf("overrides modified the source deployment:\n%s", diff)
+	}
+}
+
+func TestGenerateDeployments_maintenance(t *testing.T) {
+	src := newTestDeployment(func(d *appsv1.Deployment) {
+		d.Spec.Template.Spec.Containers[0].Args = []string{
+			"--watch-label-selector=!sharding.fluxcd.io/key",
+		}
+	})
+	fluxShardSet := &shardv1.FluxShardSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-shard-set",
+		},
+		Spec: shardv1.FluxShardSetSpec{
+			Shards: []shardv1.ShardSpec{
+				{
+					Name:        "shard-1",
+					Maintenance: true,
+					Overrides: &shardv1.ShardOverrides{
+						Replicas: pointer.Int32(3),
+					},
+				},
+				{
+					Name: "shard-2",
+				},
+			},
+		},
+	}
+
+	generatedDeps, err := GenerateDeployments(fluxShardSet, src, fluxShardSet.Spec.Shards)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replicas := *generatedDeps[0].Spec.Replicas; replicas != 0 {
+		t.Errorf("got %d replicas for the shard in maintenance, want 0", replicas)
+	}
+	if replicas := *generatedDeps[1].Spec.Replicas; replicas != 1 {
+		t.Errorf("got %d replicas for the active shard, want 1", replicas)
+	}
+}
+
+func TestGenerateDeployments_externalReplicas(t *testing.T) {
+	src := newTestDeployment(func(d *appsv1.Deployment) {
+		d.Spec.Template.Spec.Containers[0].Args = []string{
+			"--watch-label-selector=!sharding.fluxcd.io/key",
+		}
+	})
+	fluxShardSet := &shardv1.FluxShardSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-shard-set",
+		},
+		Spec: shardv1.FluxShardSetSpec{
+			ReplicasPolicy: shardv1.ExternalReplicasPolicy,
+			Shards: []shardv1.ShardSpec{
+				{
+					Name: "shard-1",
+				},
+				{
+					Name: "shard-2",
+					Overrides: &shardv1.ShardOverrides{
+						Replicas: pointer.Int32(3),
+					},
+				},
+				{
+					Name:        "shard-3",
+					Maintenance: true,
+				},
+			},
+		},
+	}
+
+	generatedDeps, err := GenerateDeployments(fluxShardSet, src, fluxShardSet.Spec.Shards)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replicas := generatedDeps[0].Spec.Replicas; replicas != nil {
+		t.Errorf("got %d replicas for the shard, want the replicas to be left to other field managers", *replicas)
+	}
+	if replicas := *generatedDeps[1].Spec.Replicas; replicas != 3 {
+		t.Errorf("got %d replicas for the shard with overrides, want 3", replicas)
+	}
+	if replicas := *generatedDeps[2].Spec.Replicas; replicas != 0 {
+		t.Errorf("got %d replicas for the shard in maintenance, want 0", replicas)
+	}
+}
+
+func TestGenerateDeployments_restart(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        []string
+	}{
+		{
+			name: "no restart keeps the restarts from the status",
+			want: []string{"2023-06-01T00:00:00Z", "", ""},
+		},
+		{
+			name: "restart all shards",
+			annotations: map[string]string{
+				shardv1.RestartedAtAnnotation: "2023-06-02T00:00:00Z",
+			},
+			want: []string{"2023-06-02T00:00:00Z", "2023-06-02T00:00:00Z", "2023-06-02T00:00:00Z"},
+		},
+		{
+			name: "restart selected shards",
+			annotations: map[string]string{
+				shardv1.RestartedAtAnnotation:   "2023-06-02T00:00:00Z",
+				shardv1.RestartShardsAnnotation: "shard-2, shard-3",
+			},
+			want: []string{"2023-06-01T00:00:00Z", "2023-06-02T00:00:00Z", "2023-06-02T00:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Args = []string{
+					"--watch-label-selector=!sharding.fluxcd.io/key",
+				}
+			})
+			fluxShardSet := &shardv1.FluxShardSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-shard-set",
+					Annotations: tt.annotations,
+				},
+				Spec: shardv1.FluxShardSetSpec{
+					Shards: []shardv1.ShardSpec{
+						{Name: "shard-1"}, {Name: "shard-2"}, {Name: "shard-3"},
+					},
+				},
+				Status: shardv1.FluxShardSetStatus{
+					Shards: []shardv1.ShardStatus{
+						{Name: "shard-1", RestartedAt: "2023-06-01T00:00:00Z"},
+					},
+				},
+			}
+
+			generatedDeps, err := GenerateDeployments(fluxShardSet, src, fluxShardSet.Spec.Shards)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, depl := range generatedDeps {
+				got = append(got, depl.Spec.Template.Annotations[shardv1.RestartedAtAnnotation])
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("failed to restart shards:\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestGenerateDeployments_errors(t *testing.T) {
 	// TODO Figure out what it means to be a flux controller and test for this
 	tests := []struct {
@@ -285,15 +635,37 @@ func TestGenerateDeployments_errors(t *testing.T) {
 	}{
 		{
 			// The deployment does not have --watch-label-selector=
-			name:    "deployment does not have sharding args",
-			src:     newTestDeployment(),
-			wantErr: "deployment flux-system/kustomize-controller is not configured to ignore sharding",
+			name:         "deployment does not have sharding args",
+			fluxShardSet: &shardv1.FluxShardSet{},
+			src:          newTestDeployment(),
+			wantErr:      "deployment flux-system/kustomize-controller is not configured to ignore sharding",
+		},
+		{
+			name: "overriding the watch label selector",
+			fluxShardSet: &shardv1.FluxShardSet{
+				Spec: shardv1.FluxShardSetSpec{
+					Shards: []shardv1.ShardSpec{
+						{
+							Name: "shard-1",
+							Overrides: &shardv1.ShardOverrides{
+								Args: []string{"--watch-label-selector=team=a"},
+							},
+						},
+					},
+				},
+			},
+			src: newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Args = []string{
+					"--watch-label-selector=!sharding.fluxcd.io/key",
+				}
+			}),
+			wantErr: "failed to apply overrides for shard shard-1: the --watch-label-selector arg can't be overridden",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := GenerateDeployments(tt.fluxShardSet, tt.src)
+			_, err := GenerateDeployments(tt.fluxShardSet, tt.src, tt.fluxShardSet.Spec.Shards)
 
 			if msg := err.Error(); msg != tt.wantErr {
 				t.Fatalf("wanted error %q, got %q", tt.wantErr, msg)
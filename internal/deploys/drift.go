@@ -0,0 +1,219 @@
+package deploys
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Drift returns the paths of the fields in the generated Deployment that have
+// different values in the existing Deployment.
+//
+// Only the labels, annotations and spec of the generated Deployment are
+// compared. Fields that are in the existing Deployment but not in the
+// generated Deployment are not drift, these are set by other field managers.
+//
+// Only the fields that are managed by the manager in the managed fields of the
+// existing Deployment are compared, along with the fields that were taken from
+// it by updates e.g. kubectl edit. Fields managed by other appliers, or through
+// a subresource e.g. the replicas scaled by a HorizontalPodAutoscaler, are not
+// drift. All the fields are compared if the existing Deployment has no managed
+// fields.
+func Drift(generated, existing *appsv1.Deployment, manager string) ([]string, error) {
+	want, err := driftFields(generated)
+	if err != nil {
+		return nil, err
+	}
+	got, err := driftFields(existing)
+	if err != nil {
+		return nil, err
+	}
+	managed, err := managedFields(existing, manager)
+	if err != nil {
+		return nil, err
+	}
+
+	drifted := []string{}
+	if managed != nil && len(managed) == 0 {
+		return drifted, nil
+	}
+	compareFields("", want, got, managed, managed == nil, &drifted)
+
+	return drifted, nil
+}
+
+// managedFields returns the merged managed fields of the manager and of
+// updates without a subresource, or nil if the Deployment has no managed
+// fields.
+func managedFields(depl *appsv1.Deployment, manager string) (map[string]interface{}, error) {
+	if len(depl.GetManagedFields()) == 0 {
+		return nil, nil
+	}
+
+	managed := map[string]interface{}{}
+	for _, entry := range depl.GetManagedFields() {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		if entry.Manager != manager && (entry.Operation != metav1.ManagedFieldsOperationUpdate || entry.Subresource != "") {
+			continue
+		}
+
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return nil, fmt.Errorf("failed to parse managed fields of Deployment %s/%s: %w", depl.GetNamespace(), depl.GetName(), err)
+		}
+		mergeFields(managed, fields)
+	}
+
+	return managed, nil
+}
+
+func mergeFields(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcChild, _ := v.(map[string]interface{})
+		dstChild, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dst[k] = srcChild
+			continue
+		}
+		mergeFields(dstChild, srcChild)
+	}
+}
+
+func driftFields(depl *appsv1.Deployment) (map[string]interface{}, error) {
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(depl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Deployment %s/%s: %w", depl.GetNamespace(), depl.GetName(), err)
+	}
+
+	metadata := map[string]interface{}{}
+	if m, ok := raw["metadata"].(map[string]interface{}); ok {
+		for _, k := range []string{"labels", "annotations"} {
+			if v, ok := m[k]; ok {
+				metadata[k] = v
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"metadata": metadata,
+		"spec":     raw["spec"],
+	}, nil
+}
+
+// compareFields records the paths of the values in want that are different in
+// got.
+//
+// fields holds the managed fields for the values, only the values with managed
+// fields are compared unless all is true.
+func compareFields(path string, want, got interface{}, fields map[string]interface{}, all bool, drifted *[]string) {
+	if !all {
+		if fields == nil {
+			return
+		}
+		// Fields without children are managed as a whole.
+		all = len(fields) == 0
+	}
+
+	switch w := want.(type) {
+	case map[string]interface{}:
+		if len(w) == 0 {
+			return
+		}
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			*drifted = append(*drifted, path)
+			return
+		}
+
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			compareFields(joinPath(path, k), w[k], g[k], childFields(fields, "f:"+k), all, drifted)
+		}
+	case []interface{}:
+		if len(w) == 0 {
+			return
+		}
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			*drifted = append(*drifted, path)
+			return
+		}
+
+		for i := range w {
+			compareFields(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], itemFields(fields, w[i]), all, drifted)
+		}
+	default:
+		if !reflect.DeepEqual(want, got) {
+			*drifted = append(*drifted, path)
+		}
+	}
+}
+
+func childFields(fields map[string]interface{}, key string) map[string]interface{} {
+	child, _ := fields[key].(map[string]interface{})
+	return child
+}
+
+// itemFields returns the managed fields of an item in a list, items in lists
+// of maps are identified by their keys and other items by their value.
+func itemFields(fields map[string]interface{}, item interface{}) map[string]interface{} {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		value, err := json.Marshal(item)
+		if err != nil {
+			return nil
+		}
+		return childFields(fields, "v:"+string(value))
+	}
+
+	for key, child := range fields {
+		if !strings.HasPrefix(key, "k:") {
+			continue
+		}
+		itemKey := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "k:")), &itemKey); err != nil {
+			continue
+		}
+		if matchesKey(itemKey, m) {
+			c, _ := child.(map[string]interface{})
+			return c
+		}
+	}
+
+	return nil
+}
+
+func matchesKey(key, item map[string]interface{}) bool {
+	for k, v := range key {
+		want, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		got, err := json.Marshal(item[k])
+		if err != nil || string(want) != string(got) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
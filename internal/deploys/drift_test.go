@@ -0,0 +1,112 @@
+package deploys
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestDrift(t *testing.T) {
+	driftTests := []struct {
+		name     string
+		existing *appsv1.Deployment
+		want     []string
+	}{
+		{
+			name:     "unchanged Deployment",
+			existing: newTestDeployment(),
+			want:     []string{},
+		},
+		{
+			name: "fields added by other field managers",
+			existing: newTestDeployment(func(d *appsv1.Deployment) {
+				d.ObjectMeta.Annotations = map[string]string{"deployment.kubernetes.io/revision": "2"}
+				d.Spec.Template.ObjectMeta.Annotations = map[string]string{"kubectl.kubernetes.io/restartedAt": "2023-06-01T10:00:00Z"}
+				d.Spec.RevisionHistoryLimit = pointer.Int32(10)
+			}),
+			want: []string{},
+		},
+		{
+			name: "changed fields",
+			existing: newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Replicas = pointer.Int32(3)
+				d.Spec.Template.Spec.Containers[0].Image = "example.com/kustomize-controller:latest"
+			}),
+			want: []string{"spec.replicas", "spec.template.spec.containers[0].image"},
+		},
+		{
+			name: "removed list items",
+			existing: newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Args = d.Spec.Template.Spec.Containers[0].Args[1:]
+			}),
+			want: []string{"spec.template.spec.containers[0].args"},
+		},
+		{
+			name: "changed labels",
+			existing: newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Template.ObjectMeta.Labels["app"] = "test"
+			}),
+			want: []string{"spec.template.metadata.labels.app"},
+		},
+		{
+			name: "fields scaled through a subresource",
+			existing: newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Replicas = pointer.Int32(3)
+				d.ManagedFields = []metav1.ManagedFieldsEntry{
+					managedFieldsEntry("flux-shard-controller", metav1.ManagedFieldsOperationApply, "", `{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"manager\"}":{"f:image":{}}}}}}}`),
+					managedFieldsEntry("kube-controller-manager", metav1.ManagedFieldsOperationUpdate, "scale", `{"f:spec":{"f:replicas":{}}}`),
+				}
+			}),
+			want: []string{},
+		},
+		{
+			name: "fields managed by other appliers",
+			existing: newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Image = "example.com/kustomize-controller:latest"
+				d.ManagedFields = []metav1.ManagedFieldsEntry{
+					managedFieldsEntry("flux-shard-controller", metav1.ManagedFieldsOperationApply, "", `{"f:spec":{"f:replicas":{}}}`),
+					managedFieldsEntry("other-controller", metav1.ManagedFieldsOperationApply, "", `{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"manager\"}":{"f:image":{}}}}}}}`),
+				}
+			}),
+			want: []string{},
+		},
+		{
+			name: "fields changed by updates",
+			existing: newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Image = "example.com/kustomize-controller:latest"
+				d.Spec.Template.Spec.Containers[0].Args = d.Spec.Template.Spec.Containers[0].Args[1:]
+				d.ManagedFields = []metav1.ManagedFieldsEntry{
+					managedFieldsEntry("flux-shard-controller", metav1.ManagedFieldsOperationApply, "", `{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"manager\"}":{"f:args":{}}}}}}}`),
+					managedFieldsEntry("kubectl-edit", metav1.ManagedFieldsOperationUpdate, "", `{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"manager\"}":{"f:image":{}}}}}}}`),
+				}
+			}),
+			want: []string{"spec.template.spec.containers[0].args", "spec.template.spec.containers[0].image"},
+		},
+	}
+
+	for _, tt := range driftTests {
+		t.Run(tt.name, func(t *testing.T) {
+			drift, err := Drift(newTestDeployment(), tt.existing, "flux-shard-controller")
+			test.AssertNoError(t, err)
+
+			if diff := cmp.Diff(tt.want, drift); diff != "" {
+				t.Fatalf("failed to detect drift:\n%s", diff)
+			}
+		})
+	}
+}
+
+func managedFieldsEntry(manager string, operation metav1.ManagedFieldsOperationType, subresource, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:     manager,
+		Operation:   operation,
+		Subresource: subresource,
+		FieldsType:  "FieldsV1",
+		FieldsV1:    &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
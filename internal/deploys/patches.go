@@ -0,0 +1,148 @@
+package deploys
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// patchParams are the values that can be referenced in patches.
+type patchParams struct {
+	ShardName    string
+	ShardSetName string
+}
+
+// applyPatches renders and applies the patches in order to the Deployment and
+// returns the patched Deployment.
+//
+// Patches are rendered as templates with the params, and can be either
+// strategic merge patches or JSON6902 patches.
+func applyPatches(depl *appsv1.Deployment, patches []v1alpha1.Patch, params patchParams) (*appsv1.Deployment, error) {
+	if len(patches) == 0 {
+		return depl, nil
+	}
+
+	original, err := json.Marshal(depl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Deployment: %w", err)
+	}
+
+	patched := original
+	for i, patch := range patches {
+		rendered, err := renderPatch(patch.Patch, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render patch %d: %w", i, err)
+		}
+
+		patched, err = applyPatch(patched, rendered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply patch %d: %w", i, err)
+		}
+	}
+
+	result := &appsv1.Deployment{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal patched Deployment: %w", err)
+	}
+
+	if result.GetName() != depl.GetName() || result.GetNamespace() != depl.GetNamespace() {
+		return nil, fmt.Errorf("patches can't change the name or namespace of Deployment %s/%s", depl.GetNamespace(), depl.GetName())
+	}
+	if err := checkShardingFields(depl, result, params); err != nil {
+		return nil, fmt.Errorf("patches can't change the %s of Deployment %s/%s", err, depl.GetNamespace(), depl.GetName())
+	}
+
+	return result, nil
+}
+
+// checkShardingFields returns an error describing the first field that the
+// shard controller generates for sharding that is different in the patched
+// Deployment.
+//
+// The selector, the shard labels and the --watch-label-selector argument of
+// the manager container select the objects and pods of the shard, changing
+// them would break the sharding.
+func checkShardingFields(depl, patched *appsv1.Deployment, params patchParams) error {
+	if !equality.Semantic.DeepEqual(depl.Spec.Selector, patched.Spec.Selector) {
+		return errors.New("selector")
+	}
+
+	shardLabels := newShardLabels(params.ShardSetName, params.ShardName)
+	keys := make([]string, 0, len(shardLabels))
+	for k := range shardLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if patched.GetLabels()[k] != depl.GetLabels()[k] || patched.Spec.Template.GetLabels()[k] != depl.Spec.Template.GetLabels()[k] {
+			return fmt.Errorf("%s label", k)
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(watchLabelSelectorArgs(depl), watchLabelSelectorArgs(patched)) {
+		return fmt.Errorf("%s argument", watchLabelSelectorFlag)
+	}
+
+	return nil
+}
+
+// watchLabelSelectorArgs returns the --watch-label-selector arguments of the
+// manager container, or nil if there is no manager container.
+func watchLabelSelectorArgs(depl *appsv1.Deployment) []string {
+	for _, container := range depl.Spec.Template.Spec.Containers {
+		if container.Name != ManagerContainerName {
+			continue
+		}
+		args := []string{}
+		for _, arg := range container.Args {
+			if strings.HasPrefix(arg, watchLabelSelectorFlag) {
+				args = append(args, arg)
+			}
+		}
+		return args
+	}
+
+	return nil
+}
+
+func renderPatch(patch string, params patchParams) ([]byte, error) {
+	tmpl, err := template.New("patch").Option("missingkey=error").Parse(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, params); err != nil {
+		return nil, err
+	}
+
+	return yaml.YAMLToJSON(out.Bytes())
+}
+
+// applyPatch applies the JSON patch to the JSON document.
+//
+// If the patch is an array it's treated as a JSON6902 patch, otherwise it's
+// treated as a strategic merge patch.
+func applyPatch(doc, patch []byte) ([]byte, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(patch), []byte("[")) {
+		decoded, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode JSON6902 patch: %w", err)
+		}
+
+		return decoded.Apply(doc)
+	}
+
+	return strategicpatch.StrategicMergePatch(doc, patch, appsv1.Deployment{})
+}
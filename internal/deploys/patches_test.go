@@ -0,0 +1,247 @@
+package deploys
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shardv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestGenerateDeployments_patches(t *testing.T) {
+	tests := []struct {
+		name          string
+		setPatches    []shardv1.Patch
+		shardPatches  []shardv1.Patch
+		wantModifiers []func(*appsv1.Deployment)
+	}{
+		{
+			name: "strategic merge patch with templated values",
+			setPatches: []shardv1.Patch{
+				{
+					Patch: `
+spec:
+  template:
+    spec:
+      nodeSelector:
+        example.com/pool: "{{ .ShardName }}"
+      priorityClassName: "{{ .ShardSetName }}-priority"
+`,
+				},
+			},
+			wantModifiers: []func(*appsv1.Deployment){
+				func(d *appsv1.Deployment) {
+					d.Spec.Template.Spec.NodeSelector = map[string]string{
+						"example.com/pool": "shard-1",
+					}
+					d.Spec.Template.Spec.PriorityClassName = "test-shard-set-priority"
+				},
+			},
+		},
+		{
+			name: "JSON6902 patch",
+			setPatches: []shardv1.Patch{
+				{
+					Patch: `
+- op: add
+  path: /spec/template/spec/tolerations
+  value:
+    - key: "example.com/shard"
+      operator: "Equal"
+      value: "{{ .ShardName }}"
+      effect: "NoSchedule"
+`,
+				},
+			},
+			wantModifiers: []func(*appsv1.Deployment){
+				func(d *appsv1.Deployment) {
+					d.Spec.Template.Spec.Tolerations = []corev1.Toleration{
+						{
+							Key:      "example.com/shard",
+							Operator: corev1.TolerationOpEqual,
+							Value:    "shard-1",
+							Effect:   corev1.TaintEffectNoSchedule,
+						},
+					}
+				},
+			},
+		},
+		{
+			name: "shard patches are applied after the shard set patches",
+			setPatches: []shardv1.Patch{
+				{
+					Patch: `
+metadata:
+  annotations:
+    example.com/team: platform
+    example.com/tier: standard
+`,
+				},
+			},
+			shardPatches: []shardv1.Patch{
+				{
+					Patch: `
+metadata:
+  annotations:
+    example.com/tier: heavy
+`,
+				},
+			},
+			wantModifiers: []func(*appsv1.Deployment){
+				func(d *appsv1.Deployment) {
+					d.Annotations = map[string]string{
+						"example.com/team": "platform",
+						"example.com/tier": "heavy",
+					}
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fluxShardSet := &shardv1.FluxShardSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-shard-set",
+				},
+				Spec: shardv1.FluxShardSetSpec{
+					SourceDeploymentRef: shardv1.SourceDeploymentReference{
+						Name: testControllerName,
+					},
+					Shards: []shardv1.ShardSpec{
+						{
+							Name:    "shard-1",
+							Patches: tt.shardPatches,
+						},
+					},
+					Patches: tt.setPatches,
+				},
+			}
+			src := newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Args = []string{
+					"--watch-label-selector=!sharding.fluxcd.io/key",
+				}
+			})
+
+			generatedDeps, err := GenerateDeployments(fluxShardSet, src, fluxShardSet.Spec.Shards)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := newTestDeployment(append([]func(*appsv1.Deployment){func(d *appsv1.Deployment) {
+				d.ObjectMeta.Labels = test.ShardLabels("shard-1")
+				d.ObjectMeta.Name = "kustomize-controller-shard-1"
+				d.Spec.Template.Spec.Containers[0].Args = []string{
+					"--watch-label-selector=sharding.fluxcd.io/key in (shard-1)",
+				}
+				d.Spec.Selector = &metav1.LabelSelector{
+					MatchLabels: test.ShardLabels("shard-1", map[string]string{
+						"app": "kustomize-controller",
+					}),
+				}
+				d.Spec.Template.ObjectMeta.Labels = test.ShardLabels("shard-1", map[string]string{
+					"app": "kustomize-controller",
+				})
+			}}, tt.wantModifiers...)...)
+
+			if diff := cmp.Diff([]*appsv1.Deployment{want}, generatedDeps); diff != "" {
+				t.Fatalf("generated deployments dont match wanted: \n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateDeployments_patchErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		patches []shardv1.Patch
+		wantErr string
+	}{
+		{
+			name: "invalid template",
+			patches: []shardv1.Patch{
+				{Patch: `metadata: {{ .ShardName`},
+			},
+			wantErr: "failed to apply patches for shard shard-1: failed to render patch 0: .*unclosed action",
+		},
+		{
+			name: "unknown template value",
+			patches: []shardv1.Patch{
+				{Patch: `metadata: {annotations: {test: "{{ .Unknown }}"}}`},
+			},
+			wantErr: "failed to apply patches for shard shard-1: failed to render patch 0: .*can't evaluate field Unknown",
+		},
+		{
+			name: "invalid JSON6902 patch",
+			patches: []shardv1.Patch{
+				{Patch: `[{"op": "remove", "path": "/spec/template/spec/unknown"}]`},
+			},
+			wantErr: "failed to apply patches for shard shard-1: failed to apply patch 0: .*",
+		},
+		{
+			name: "patch renames the Deployment",
+			patches: []shardv1.Patch{
+				{Patch: `metadata: {name: renamed}`},
+			},
+			wantErr: "failed to apply patches for shard shard-1: patches can't change the name or namespace of Deployment flux-system/kustomize-controller-shard-1",
+		},
+		{
+			name: "patch changes the selector",
+			patches: []shardv1.Patch{
+				{Patch: `spec: {selector: {matchLabels: {example.com/pool: shards}}}`},
+			},
+			wantErr: "failed to apply patches for shard shard-1: patches can't change the selector of Deployment flux-system/kustomize-controller-shard-1",
+		},
+		{
+			name: "patch changes a shard label",
+			patches: []shardv1.Patch{
+				{Patch: `[{"op": "replace", "path": "/spec/template/metadata/labels/templates.weave.works~1shard", "value": "shard-2"}]`},
+			},
+			wantErr: "failed to apply patches for shard shard-1: patches can't change the templates.weave.works/shard label of Deployment flux-system/kustomize-controller-shard-1",
+		},
+		{
+			name: "patch removes a shard label",
+			patches: []shardv1.Patch{
+				{Patch: `[{"op": "remove", "path": "/metadata/labels/sharding.fluxcd.io~1role"}]`},
+			},
+			wantErr: "failed to apply patches for shard shard-1: patches can't change the sharding.fluxcd.io/role label of Deployment flux-system/kustomize-controller-shard-1",
+		},
+		{
+			name: "patch changes the watch label selector",
+			patches: []shardv1.Patch{
+				{Patch: `[{"op": "replace", "path": "/spec/template/spec/containers/0/args/0", "value": "--watch-label-selector=!sharding.fluxcd.io/key"}]`},
+			},
+			wantErr: "failed to apply patches for shard shard-1: patches can't change the --watch-label-selector argument of Deployment flux-system/kustomize-controller-shard-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fluxShardSet := &shardv1.FluxShardSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-shard-set",
+				},
+				Spec: shardv1.FluxShardSetSpec{
+					Shards: []shardv1.ShardSpec{
+						{
+							Name: "shard-1",
+						},
+					},
+					Patches: tt.patches,
+				},
+			}
+			src := newTestDeployment(func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers[0].Args = []string{
+					"--watch-label-selector=!sharding.fluxcd.io/key",
+				}
+			})
+
+			_, err := GenerateDeployments(fluxShardSet, src, fluxShardSet.Spec.Shards)
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package fluxobjects
+
+import (
+	"context"
+	"fmt"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Kinds are the Flux kinds that can be sharded with the
+// sharding.fluxcd.io/key label.
+var Kinds = []schema.GroupKind{
+	{Group: "kustomize.toolkit.fluxcd.io", Kind: "Kustomization"},
+	{Group: "helm.toolkit.fluxcd.io", Kind: "HelmRelease"},
+	{Group: "source.toolkit.fluxcd.io", Kind: "GitRepository"},
+	{Group: "source.toolkit.fluxcd.io", Kind: "OCIRepository"},
+	{Group: "source.toolkit.fluxcd.io", Kind: "HelmRepository"},
+	{Group: "source.toolkit.fluxcd.io", Kind: "HelmChart"},
+	{Group: "source.toolkit.fluxcd.io", Kind: "Bucket"},
+	{Group: "notification.toolkit.fluxcd.io", Kind: "Alert"},
+	{Group: "notification.toolkit.fluxcd.io", Kind: "Provider"},
+	{Group: "notification.toolkit.fluxcd.io", Kind: "Receiver"},
+	{Group: "image.toolkit.fluxcd.io", Kind: "ImageRepository"},
+	{Group: "image.toolkit.fluxcd.io", Kind: "ImagePolicy"},
+	{Group: "image.toolkit.fluxcd.io", Kind: "ImageUpdateAutomation"},
+}
+
+// ControllerKinds are the names of the kinds reconciled by each of the Flux
+// controllers, keyed by the name of the controller's Deployment.
+var ControllerKinds = map[string][]string{
+	"kustomize-controller":        {"Kustomization"},
+	"helm-controller":             {"HelmRelease"},
+	"source-controller":           {"GitRepository", "OCIRepository", "HelmRepository", "HelmChart", "Bucket"},
+	"notification-controller":     {"Alert", "Provider", "Receiver"},
+	"image-reflector-controller":  {"ImageRepository", "ImagePolicy"},
+	"image-automation-controller": {"ImageUpdateAutomation"},
+}
+
+// ParseKinds returns the GroupKinds for the named Flux kinds e.g.
+// "Kustomization".
+//
+// If no names are provided, all the Flux Kinds are returned.
+func ParseKinds(names []string) ([]schema.GroupKind, error) {
+	if len(names) == 0 {
+		return Kinds, nil
+	}
+
+	kinds := []schema.GroupKind{}
+	for _, name := range names {
+		gk, err := parseKind(name)
+		if err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, gk)
+	}
+
+	return kinds, nil
+}
+
+func parseKind(name string) (schema.GroupKind, error) {
+	for _, gk := range Kinds {
+		if gk.Kind == name {
+			return gk, nil
+		}
+	}
+
+	return schema.GroupKind{}, fmt.Errorf("unknown Flux kind %q", name)
+}
+
+// Matches returns true if the object is one of the named kinds, or any Flux
+// kind if no kinds are named, and matches the selector.
+func Matches(names []string, selector *metav1.LabelSelector, obj client.Object) (bool, error) {
+	kinds, err := ParseKinds(names)
+	if err != nil {
+		return false, err
+	}
+
+	gk := obj.GetObjectKind().GroupVersionKind().GroupKind()
+	kindMatches := false
+	for _, kind := range kinds {
+		if kind == gk {
+			kindMatches = true
+			break
+		}
+	}
+	if !kindMatches || selector == nil {
+		return kindMatches, nil
+	}
+
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse selector: %w", err)
+	}
+
+	return s.Matches(labels.Set(obj.GetLabels())), nil
+}
+
+// InstalledKinds returns the GroupVersionKinds for the kinds that are
+// installed in the cluster, using the preferred version for each kind.
+func InstalledKinds(mapper apimeta.RESTMapper, kinds []schema.GroupKind) ([]schema.GroupVersionKind, error) {
+	installed := []schema.GroupVersionKind{}
+	for _, gk := range kinds {
+		mapping, err := mapper.RESTMapping(gk)
+		if err != nil {
+			if apimeta.IsNoMatchError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get REST mapping for %s: %w", gk, err)
+		}
+		installed = append(installed, mapping.GroupVersionKind)
+	}
+
+	return installed, nil
+}
+
+// List returns the metadata for the objects of the kinds that are installed in
+// the cluster.
+//
+// The GroupVersionKind of each of the returned objects is set.
+func List(ctx context.Context, c client.Client, kinds []schema.GroupKind, opts ...client.ListOption) ([]metav1.PartialObjectMetadata, error) {
+	gvks, err := InstalledKinds(c.RESTMapper(), kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []metav1.PartialObjectMetadata{}
+	for _, gvk := range gvks {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.List(ctx, list, opts...); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", gvk.Kind, err)
+		}
+
+		for i := range list.Items {
+			item := list.Items[i]
+			item.SetGroupVersionKind(gvk)
+			objects = append(objects, item)
+		}
+	}
+
+	return objects, nil
+}
+
+// NewObject returns a PartialObjectMetadata for the GroupVersionKind, this can
+// be used to watch the metadata for objects.
+func NewObject(gvk schema.GroupVersionKind) *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvk)
+
+	return obj
+}
@@ -0,0 +1,50 @@
+package fluxobjects
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseKinds(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []schema.GroupKind
+	}{
+		{
+			name: "no names",
+			want: Kinds,
+		},
+		{
+			name:  "named kinds",
+			names: []string{"Kustomization", "GitRepository"},
+			want: []schema.GroupKind{
+				{Group: "kustomize.toolkit.fluxcd.io", Kind: "Kustomization"},
+				{Group: "source.toolkit.fluxcd.io", Kind: "GitRepository"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kinds, err := ParseKinds(tt.names)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, kinds); diff != "" {
+				t.Fatalf("failed to parse kinds:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseKinds_errors(t *testing.T) {
+	_, err := ParseKinds([]string{"Kustomization", "Deployment"})
+	if msg := fmt.Sprint(err); msg != `unknown Flux kind "Deployment"` {
+		t.Fatalf("got error %q", msg)
+	}
+}
@@ -0,0 +1,314 @@
+package generators
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+)
+
+const (
+	defaultNameTemplate = "shard-{{ .Index }}"
+	defaultGracePeriod  = 5 * time.Minute
+)
+
+// ExpandShards returns the shards declared in the FluxShardSet followed by the
+// shards generated by its generators.
+//
+// Generated shards with the same name as an earlier shard are skipped, this
+// means that declaring a shard takes precedence over generating it.
+//
+// The shards found by discovery generators are recorded in the status of the
+// FluxShardSet, shards that are no longer discovered are kept until the grace
+// period has elapsed.
+func ExpandShards(ctx context.Context, c client.Client, fluxShardSet *v1alpha1.FluxShardSet) ([]v1alpha1.ShardSpec, error) {
+	shards := []v1alpha1.ShardSpec{}
+	seen := map[string]bool{}
+	for _, shard := range fluxShardSet.Spec.Shards {
+		if seen[shard.Name] {
+			continue
+		}
+		seen[shard.Name] = true
+		shards = append(shards, shard)
+	}
+
+	discovered := map[string]bool{}
+	for i, generator := range fluxShardSet.Spec.Generators {
+		names, err := generateNames(ctx, c, fluxShardSet.GetNamespace(), generator)
+		if err != nil {
+			return nil, fmt.Errorf("generator %d failed: %w", i, err)
+		}
+
+		for _, name := range names {
+			if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+				return nil, fmt.Errorf("generator %d generated invalid shard name %q: %s", i, name, strings.Join(errs, ", "))
+			}
+			if generator.Discovery != nil {
+				discovered[name] = true
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			shards = append(shards, v1alpha1.ShardSpec{Name: name})
+		}
+	}
+
+	fluxShardSet.Status.DiscoveredShards = updateDiscoveredShards(fluxShardSet, discovered, time.Now())
+	for _, shard := range fluxShardSet.Status.DiscoveredShards {
+		if seen[shard.Name] {
+			continue
+		}
+		seen[shard.Name] = true
+		shards = append(shards, v1alpha1.ShardSpec{Name: shard.Name})
+	}
+
+	return shards, nil
+}
+
+// ExpandStaticShards returns the shards declared in the FluxShardSet followed
+// by the shards generated by its range and ConfigMap generators.
+//
+// Unlike ExpandShards, the discovery generators are skipped, the Flux objects
+// are not listed and the FluxShardSet is not changed.
+func ExpandStaticShards(ctx context.Context, c client.Client, fluxShardSet *v1alpha1.FluxShardSet) ([]v1alpha1.ShardSpec, error) {
+	shards := []v1alpha1.ShardSpec{}
+	seen := map[string]bool{}
+	for _, shard := range fluxShardSet.Spec.Shards {
+		if seen[shard.Name] {
+			continue
+		}
+		seen[shard.Name] = true
+		shards = append(shards, shard)
+	}
+
+	for i, generator := range fluxShardSet.Spec.Generators {
+		if generator.Discovery != nil {
+			continue
+		}
+		names, err := generateNames(ctx, c, fluxShardSet.GetNamespace(), generator)
+		if err != nil {
+			return nil, fmt.Errorf("generator %d failed: %w", i, err)
+		}
+
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			shards = append(shards, v1alpha1.ShardSpec{Name: name})
+		}
+	}
+
+	return shards, nil
+}
+
+// DiscoveryRequeueAfter returns how long until the grace period for the next
+// missing discovered shard elapses, or zero if no discovered shards are
+// missing.
+func DiscoveryRequeueAfter(fluxShardSet *v1alpha1.FluxShardSet) time.Duration {
+	gracePeriod := discoveryGracePeriod(fluxShardSet)
+	var next time.Duration
+	for _, shard := range fluxShardSet.Status.DiscoveredShards {
+		if shard.MissingSince == nil {
+			continue
+		}
+		remaining := time.Until(shard.MissingSince.Add(gracePeriod))
+		if remaining < time.Second {
+			remaining = time.Second
+		}
+		if next == 0 || remaining < next {
+			next = remaining
+		}
+	}
+
+	return next
+}
+
+// UsesDiscovery returns true if the FluxShardSet has a discovery generator.
+func UsesDiscovery(fluxShardSet *v1alpha1.FluxShardSet) bool {
+	for _, generator := range fluxShardSet.Spec.Generators {
+		if generator.Discovery != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updateDiscoveredShards returns the discovered shards to record in the status.
+//
+// Previously discovered shards that are missing are kept with the time they
+// went missing, until the longest grace period of the discovery generators has
+// elapsed.
+func updateDiscoveredShards(fluxShardSet *v1alpha1.FluxShardSet, discovered map[string]bool, now time.Time) []v1alpha1.DiscoveredShard {
+	gracePeriod := discoveryGracePeriod(fluxShardSet)
+	result := []v1alpha1.DiscoveredShard{}
+	for _, shard := range fluxShardSet.Status.DiscoveredShards {
+		if discovered[shard.Name] {
+			continue
+		}
+		missingSince := shard.MissingSince
+		if missingSince == nil {
+			missingSince = &metav1.Time{Time: now}
+		}
+		if now.Sub(missingSince.Time) >= gracePeriod {
+			continue
+		}
+		result = append(result, v1alpha1.DiscoveredShard{Name: shard.Name, MissingSince: missingSince})
+	}
+
+	for name := range discovered {
+		result = append(result, v1alpha1.DiscoveredShard{Name: name})
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+func discoveryGracePeriod(fluxShardSet *v1alpha1.FluxShardSet) time.Duration {
+	var gracePeriod time.Duration
+	for _, generator := range fluxShardSet.Spec.Generators {
+		if generator.Discovery == nil {
+			continue
+		}
+		period := defaultGracePeriod
+		if generator.Discovery.GracePeriod != nil {
+			period = generator.Discovery.GracePeriod.Duration
+		}
+		if period > gracePeriod {
+			gracePeriod = period
+		}
+	}
+
+	return gracePeriod
+}
+
+// ConfigMapKeys returns the keys for the ConfigMaps referenced by generators
+// in the FluxShardSet.
+func ConfigMapKeys(fluxShardSet *v1alpha1.FluxShardSet) []client.ObjectKey {
+	keys := []client.ObjectKey{}
+	for _, generator := range fluxShardSet.Spec.Generators {
+		if generator.ConfigMap != nil {
+			keys = append(keys, client.ObjectKey{Namespace: fluxShardSet.GetNamespace(), Name: generator.ConfigMap.Name})
+		}
+	}
+
+	return keys
+}
+
+func generateNames(ctx context.Context, c client.Client, namespace string, generator v1alpha1.ShardGenerator) ([]string, error) {
+	switch {
+	case generator.Range != nil:
+		return generateRange(generator.Range)
+	case generator.ConfigMap != nil:
+		return generateFromConfigMap(ctx, c, namespace, generator.ConfigMap)
+	case generator.Discovery != nil:
+		return generateFromDiscovery(ctx, c, generator.Discovery)
+	}
+
+	return nil, fmt.Errorf("no generator configured")
+}
+
+func generateRange(gen *v1alpha1.RangeGenerator) ([]string, error) {
+	nameTemplate := gen.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = defaultNameTemplate
+	}
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse name template: %w", err)
+	}
+
+	names := []string{}
+	for i := gen.Start; i < gen.Start+gen.Count; i++ {
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, map[string]any{"Index": i}); err != nil {
+			return nil, fmt.Errorf("failed to render name template: %w", err)
+		}
+		names = append(names, out.String())
+	}
+
+	return names, nil
+}
+
+func generateFromConfigMap(ctx context.Context, c client.Reader, namespace string, gen *v1alpha1.ConfigMapGenerator) ([]string, error) {
+	key := client.ObjectKey{Namespace: namespace, Name: gen.Name}
+	var configMap corev1.ConfigMap
+	if err := c.Get(ctx, key, &configMap); err != nil {
+		return nil, fmt.Errorf("failed to get ConfigMap %s: %w", key, err)
+	}
+
+	value, ok := configMap.Data[gen.Key]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s has no key %q", key, gen.Key)
+	}
+
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}), nil
+}
+
+func generateFromDiscovery(ctx context.Context, c client.Client, gen *v1alpha1.DiscoveryGenerator) ([]string, error) {
+	kinds, err := fluxobjects.ParseKinds(gen.Kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := labels.Everything()
+	if gen.Selector != nil {
+		selector, err = metav1.LabelSelectorAsSelector(gen.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse selector: %w", err)
+		}
+	}
+	hasKey, err := labels.NewRequirement(deploys.ShardKeyLabel, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+	selector = selector.Add(*hasKey)
+
+	objects, err := fluxobjects.List(ctx, c, kinds, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	names := []string{}
+	for _, obj := range objects {
+		name := obj.GetLabels()[deploys.ShardKeyLabel]
+		// Objects with keys that can't be shard names are skipped rather than
+		// failing discovery for all shards.
+		if seen[name] || len(validation.IsDNS1123Label(name)) > 0 {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
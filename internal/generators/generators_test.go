@@ -0,0 +1,331 @@
+package generators
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestExpandShards(t *testing.T) {
+	shardsConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "shards",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"shards": "team-a, team-b\nteam-c\n",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		shards     []templatesv1.ShardSpec
+		generators []templatesv1.ShardGenerator
+		want       []templatesv1.ShardSpec
+	}{
+		{
+			name: "no generators",
+			shards: []templatesv1.ShardSpec{
+				{Name: "shard-a"},
+			},
+			want: []templatesv1.ShardSpec{
+				{Name: "shard-a"},
+			},
+		},
+		{
+			name: "range generator with default name template",
+			generators: []templatesv1.ShardGenerator{
+				{Range: &templatesv1.RangeGenerator{Count: 3}},
+			},
+			want: []templatesv1.ShardSpec{
+				{Name: "shard-0"},
+				{Name: "shard-1"},
+				{Name: "shard-2"},
+			},
+		},
+		{
+			name: "range generator with start and name template",
+			generators: []templatesv1.ShardGenerator{
+				{Range: &templatesv1.RangeGenerator{Start: 10, Count: 2, NameTemplate: "kc-{{ .Index }}"}},
+			},
+			want: []templatesv1.ShardSpec{
+				{Name: "kc-10"},
+				{Name: "kc-11"},
+			},
+		},
+		{
+			name: "configmap generator",
+			generators: []templatesv1.ShardGenerator{
+				{ConfigMap: &templatesv1.ConfigMapGenerator{Name: "shards", Key: "shards"}},
+			},
+			want: []templatesv1.ShardSpec{
+				{Name: "team-a"},
+				{Name: "team-b"},
+				{Name: "team-c"},
+			},
+		},
+		{
+			name: "declared shards take precedence over generated shards",
+			shards: []templatesv1.ShardSpec{
+				{
+					Name: "shard-1",
+					Overrides: &templatesv1.ShardOverrides{
+						Replicas: pointer.Int32(3),
+					},
+				},
+			},
+			generators: []templatesv1.ShardGenerator{
+				{Range: &templatesv1.RangeGenerator{Count: 2}},
+				{Range: &templatesv1.RangeGenerator{Count: 3}},
+			},
+			want: []templatesv1.ShardSpec{
+				{
+					Name: "shard-1",
+					Overrides: &templatesv1.ShardOverrides{
+						Replicas: pointer.Int32(3),
+					},
+				},
+				{Name: "shard-0"},
+				{Name: "shard-2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+				set.Spec.Shards = tt.shards
+				set.Spec.Generators = tt.generators
+			})
+			cl := fake.NewClientBuilder().WithObjects(shardsConfigMap).Build()
+
+			shards, err := ExpandShards(context.TODO(), cl, set)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, shards); diff != "" {
+				t.Fatalf("failed to expand shards:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExpandShards_errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		generators []templatesv1.ShardGenerator
+		wantErr    string
+	}{
+		{
+			name: "no generator configured",
+			generators: []templatesv1.ShardGenerator{
+				{},
+			},
+			wantErr: "generator 0 failed: no generator configured",
+		},
+		{
+			name: "invalid name template",
+			generators: []templatesv1.ShardGenerator{
+				{Range: &templatesv1.RangeGenerator{Count: 1, NameTemplate: "shard-{{ .Index"}},
+			},
+			wantErr: "generator 0 failed: failed to parse name template: .*",
+		},
+		{
+			name: "invalid shard name",
+			generators: []templatesv1.ShardGenerator{
+				{Range: &templatesv1.RangeGenerator{Count: 1, NameTemplate: "Shard_{{ .Index }}"}},
+			},
+			wantErr: `generator 0 generated invalid shard name "Shard_0": .*`,
+		},
+		{
+			name: "missing configmap",
+			generators: []templatesv1.ShardGenerator{
+				{ConfigMap: &templatesv1.ConfigMapGenerator{Name: "missing", Key: "shards"}},
+			},
+			wantErr: `generator 0 failed: failed to get ConfigMap default/missing: configmaps "missing" not found`,
+		},
+		{
+			name: "missing configmap key",
+			generators: []templatesv1.ShardGenerator{
+				{ConfigMap: &templatesv1.ConfigMapGenerator{Name: "shards", Key: "missing"}},
+			},
+			wantErr: `generator 0 failed: ConfigMap default/shards has no key "missing"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+				set.Spec.Generators = tt.generators
+			})
+			cl := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "shards", Namespace: "default"},
+			}).Build()
+
+			_, err := ExpandShards(context.TODO(), cl, set)
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestConfigMapKeys(t *testing.T) {
+	set := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+		set.Spec.Generators = []templatesv1.ShardGenerator{
+			{Range: &templatesv1.RangeGenerator{Count: 2}},
+			{ConfigMap: &templatesv1.ConfigMapGenerator{Name: "shards", Key: "shards"}},
+		}
+	})
+
+	want := []client.ObjectKey{{Namespace: "default", Name: "shards"}}
+	if diff := cmp.Diff(want, ConfigMapKeys(set)); diff != "" {
+		t.Fatalf("failed to get ConfigMap keys:\n%s", diff)
+	}
+}
+
+func TestExpandShards_discovery(t *testing.T) {
+	cl := test.NewFakeFluxClient(
+		test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "team-a"}),
+		test.NewFluxObject("Kustomization", "default", "app-2", map[string]string{deploys.ShardKeyLabel: "team-a"}),
+		test.NewFluxObject("GitRepository", "apps", "repo", map[string]string{deploys.ShardKeyLabel: "team-b", "env": "prod"}),
+		test.NewFluxObject("HelmRelease", "apps", "release", map[string]string{deploys.ShardKeyLabel: "Not_Valid"}),
+		test.NewFluxObject("HelmRelease", "apps", "unsharded", nil),
+	)
+
+	tests := []struct {
+		name      string
+		generator templatesv1.DiscoveryGenerator
+		want      []templatesv1.ShardSpec
+	}{
+		{
+			name:      "all kinds",
+			generator: templatesv1.DiscoveryGenerator{},
+			want: []templatesv1.ShardSpec{
+				{Name: "team-a"},
+				{Name: "team-b"},
+			},
+		},
+		{
+			name:      "restricted kinds",
+			generator: templatesv1.DiscoveryGenerator{Kinds: []string{"GitRepository"}},
+			want: []templatesv1.ShardSpec{
+				{Name: "team-b"},
+			},
+		},
+		{
+			name: "with selector",
+			generator: templatesv1.DiscoveryGenerator{Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"env": "prod"},
+			}},
+			want: []templatesv1.ShardSpec{
+				{Name: "team-b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+				set.Spec.Generators = []templatesv1.ShardGenerator{
+					{Discovery: &tt.generator},
+				}
+			})
+
+			shards, err := ExpandShards(context.TODO(), cl, set)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, shards); diff != "" {
+				t.Fatalf("failed to expand shards:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExpandShards_discoveryGracePeriod(t *testing.T) {
+	cl := test.NewFakeFluxClient(
+		test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "team-a"}),
+	)
+	set := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+		set.Spec.Generators = []templatesv1.ShardGenerator{
+			{Discovery: &templatesv1.DiscoveryGenerator{
+				GracePeriod: &metav1.Duration{Duration: time.Minute},
+			}},
+		}
+		set.Status.DiscoveredShards = []templatesv1.DiscoveredShard{
+			{Name: "team-b"},
+			{Name: "team-c", MissingSince: &metav1.Time{Time: time.Now().Add(-2 * time.Minute)}},
+		}
+	})
+
+	shards, err := ExpandShards(context.TODO(), cl, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []templatesv1.ShardSpec{
+		{Name: "team-a"},
+		{Name: "team-b"},
+	}
+	if diff := cmp.Diff(want, shards); diff != "" {
+		t.Fatalf("failed to expand shards:\n%s", diff)
+	}
+
+	if l := len(set.Status.DiscoveredShards); l != 2 {
+		t.Fatalf("got %d discovered shards, want 2", l)
+	}
+	if set.Status.DiscoveredShards[0].MissingSince != nil {
+		t.Errorf("team-a should not be missing")
+	}
+	if set.Status.DiscoveredShards[1].MissingSince == nil {
+		t.Errorf("team-b should be missing")
+	}
+
+	if d := DiscoveryRequeueAfter(set); d <= 0 || d > time.Minute {
+		t.Errorf("got requeue after %v, want up to 1m", d)
+	}
+}
+
+func TestExpandStaticShards(t *testing.T) {
+	cl := test.NewFakeFluxClient(
+		test.NewFluxObject("Kustomization", "default", "app-1", map[string]string{deploys.ShardKeyLabel: "team-a"}),
+	)
+	set := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+		set.Spec.Shards = []templatesv1.ShardSpec{{Name: "shard-a"}}
+		set.Spec.Generators = []templatesv1.ShardGenerator{
+			{Discovery: &templatesv1.DiscoveryGenerator{}},
+			{Range: &templatesv1.RangeGenerator{Count: 2}},
+		}
+		set.Status.DiscoveredShards = []templatesv1.DiscoveredShard{{Name: "team-b"}}
+	})
+	original := set.DeepCopy()
+
+	shards, err := ExpandStaticShards(context.TODO(), cl, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []templatesv1.ShardSpec{
+		{Name: "shard-a"},
+		{Name: "shard-0"},
+		{Name: "shard-1"},
+	}
+	if diff := cmp.Diff(want, shards); diff != "" {
+		t.Fatalf("failed to expand shards:\n%s", diff)
+	}
+	if diff := cmp.Diff(original, set); diff != "" {
+		t.Fatalf("expanding the shards changed the FluxShardSet:\n%s", diff)
+	}
+}
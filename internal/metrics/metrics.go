@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+)
+
+// The results of writing a shard Deployment.
+const (
+	AppliedWrite = "applied"
+	SkippedWrite = "skipped"
+)
+
+var (
+	shards = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "flux_shard_controller_shards",
+			Help: "The number of shards for a FluxShardSet.",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	shardReady = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "flux_shard_controller_shard_ready",
+			Help: "Whether the Deployment for a shard is ready, 1 if it is ready and 0 otherwise.",
+		},
+		[]string{"namespace", "name", "shard"},
+	)
+
+	assignedObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "flux_shard_controller_assigned_objects",
+			Help: "The number of Flux objects with the shard key of a shard, by kind.",
+		},
+		[]string{"namespace", "name", "shard", "kind"},
+	)
+
+	unassignedObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "flux_shard_controller_unassigned_objects",
+			Help: "The number of Flux objects selected by a FluxShardSet without a shard key.",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	orphanedObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "flux_shard_controller_orphaned_objects",
+			Help: "The number of Flux objects with shard keys that are not served by any FluxShardSet.",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	deploymentWritesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "flux_shard_controller_deployment_writes_total",
+			Help: "The number of shard Deployments that were applied or skipped because they were unchanged.",
+		},
+		[]string{"namespace", "name", "result"},
+	)
+
+	reconcileTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "flux_shard_controller_reconcile_total",
+			Help: "The number of reconciliations of a FluxShardSet, by the reason of the Ready condition.",
+		},
+		[]string{"namespace", "name", "reason"},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(
+		shards,
+		shardReady,
+		assignedObjects,
+		unassignedObjects,
+		orphanedObjects,
+		deploymentWritesTotal,
+		reconcileTotal,
+	)
+}
+
+// RecordShards records the number of shards and the readiness of the shard
+// Deployments from the status of the FluxShardSet.
+func RecordShards(set *v1alpha1.FluxShardSet) {
+	labels := setLabels(set)
+	shards.With(labels).Set(float64(len(set.Status.Shards)))
+
+	shardReady.DeletePartialMatch(labels)
+	for _, shard := range set.Status.Shards {
+		ready := 0.0
+		if shard.Health == status.CurrentStatus.String() {
+			ready = 1.0
+		}
+		shardReady.WithLabelValues(set.GetNamespace(), set.GetName(), shard.Name).Set(ready)
+	}
+}
+
+// RecordObjects records the number of Flux objects assigned to each shard by
+// kind, and the number of objects without a shard key.
+//
+// The assigned objects are keyed by shard and then by kind.
+func RecordObjects(set *v1alpha1.FluxShardSet, assigned map[string]map[string]int, unassigned int) {
+	labels := setLabels(set)
+	assignedObjects.DeletePartialMatch(labels)
+	for shard, kinds := range assigned {
+		for kind, count := range kinds {
+			assignedObjects.WithLabelValues(set.GetNamespace(), set.GetName(), shard, kind).Set(float64(count))
+		}
+	}
+	unassignedObjects.With(labels).Set(float64(unassigned))
+}
+
+// RecordOrphanedObjects records the number of orphaned objects found when
+// reconciling the FluxShardSet.
+func RecordOrphanedObjects(set *v1alpha1.FluxShardSet, count int) {
+	orphanedObjects.With(setLabels(set)).Set(float64(count))
+}
+
+// RecordDeploymentWrite counts a shard Deployment that was applied or skipped
+// when reconciling the FluxShardSet.
+func RecordDeploymentWrite(set *v1alpha1.FluxShardSet, result string) {
+	deploymentWritesTotal.WithLabelValues(set.GetNamespace(), set.GetName(), result).Inc()
+}
+
+// RecordReconcile counts a reconciliation of the FluxShardSet with the reason
+// of the Ready condition.
+func RecordReconcile(set *v1alpha1.FluxShardSet, reason string) {
+	reconcileTotal.WithLabelValues(set.GetNamespace(), set.GetName(), reason).Inc()
+}
+
+// Delete removes the metrics for a FluxShardSet that has been deleted.
+func Delete(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	shards.DeletePartialMatch(labels)
+	shardReady.DeletePartialMatch(labels)
+	assignedObjects.DeletePartialMatch(labels)
+	unassignedObjects.DeletePartialMatch(labels)
+	orphanedObjects.DeletePartialMatch(labels)
+	deploymentWritesTotal.DeletePartialMatch(labels)
+	reconcileTotal.DeletePartialMatch(labels)
+}
+
+func setLabels(set *v1alpha1.FluxShardSet) prometheus.Labels {
+	return prometheus.Labels{"namespace": set.GetNamespace(), "name": set.GetName()}
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+)
+
+func TestRecordShards(t *testing.T) {
+	set := &v1alpha1.FluxShardSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-shard-set", Namespace: "default"},
+		Status: v1alpha1.FluxShardSetStatus{
+			Shards: []v1alpha1.ShardStatus{
+				{Name: "shard-1", Health: "Current"},
+				{Name: "shard-2", Health: "InProgress"},
+			},
+		},
+	}
+	defer Delete("default", "test-shard-set")
+
+	RecordShards(set)
+
+	want := `
+# HELP flux_shard_controller_shard_ready Whether the Deployment for a shard is ready, 1 if it is ready and 0 otherwise.
+# TYPE flux_shard_controller_shard_ready gauge
+flux_shard_controller_shard_ready{name="test-shard-set",namespace="default",shard="shard-1"} 1
+flux_shard_controller_shard_ready{name="test-shard-set",namespace="default",shard="shard-2"} 0
+`
+	if err := testutil.CollectAndCompare(shardReady, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removed shards are no longer reported.
+	set.Status.Shards = set.Status.Shards[:1]
+	RecordShards(set)
+
+	want = `
+# HELP flux_shard_controller_shard_ready Whether the Deployment for a shard is ready, 1 if it is ready and 0 otherwise.
+# TYPE flux_shard_controller_shard_ready gauge
+flux_shard_controller_shard_ready{name="test-shard-set",namespace="default",shard="shard-1"} 1
+`
+	if err := testutil.CollectAndCompare(shardReady, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(shards.WithLabelValues("default", "test-shard-set")); v != 1 {
+		t.Fatalf("got %v shards, want 1", v)
+	}
+}
+
+func TestRecordObjects(t *testing.T) {
+	set := &v1alpha1.FluxShardSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-shard-set", Namespace: "default"},
+	}
+	defer Delete("default", "test-shard-set")
+
+	RecordObjects(set, map[string]map[string]int{
+		"shard-1": {"Kustomization": 2, "HelmRelease": 1},
+		"shard-2": {},
+	}, 3)
+
+	want := `
+# HELP flux_shard_controller_assigned_objects The number of Flux objects with the shard key of a shard, by kind.
+# TYPE flux_shard_controller_assigned_objects gauge
+flux_shard_controller_assigned_objects{kind="HelmRelease",name="test-shard-set",namespace="default",shard="shard-1"} 1
+flux_shard_controller_assigned_objects{kind="Kustomization",name="test-shard-set",namespace="default",shard="shard-1"} 2
+`
+	if err := testutil.CollectAndCompare(assignedObjects, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(unassignedObjects.WithLabelValues("default", "test-shard-set")); v != 3 {
+		t.Fatalf("got %v unassigned objects, want 3", v)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	set := &v1alpha1.FluxShardSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-shard-set", Namespace: "default"},
+	}
+	RecordReconcile(set, v1alpha1.ReconciliationSucceededReason)
+	RecordOrphanedObjects(set, 2)
+
+	Delete("default", "test-shard-set")
+
+	if n := testutil.CollectAndCount(reconcileTotal); n != 0 {
+		t.Fatalf("got %d reconcile metrics, want 0", n)
+	}
+	if n := testutil.CollectAndCount(orphanedObjects); n != 0 {
+		t.Fatalf("got %d orphaned object metrics, want 0", n)
+	}
+}
@@ -0,0 +1,73 @@
+package orphans
+
+import (
+	"context"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+)
+
+// Find returns the Flux objects of the named kinds that are labelled with a
+// shard key that is not in the served shards.
+//
+// The objects are sorted by kind, namespace and name.
+func Find(ctx context.Context, c client.Client, kinds []string, served map[string]bool) ([]v1alpha1.OrphanedObject, error) {
+	gks, err := fluxobjects.ParseKinds(kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	hasKey, err := labels.NewRequirement(deploys.ShardKeyLabel, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := fluxobjects.List(ctx, c, gks, client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*hasKey)})
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []v1alpha1.OrphanedObject{}
+	for _, obj := range objects {
+		key := obj.GetLabels()[deploys.ShardKeyLabel]
+		if served[key] {
+			continue
+		}
+		orphans = append(orphans, v1alpha1.OrphanedObject{
+			Kind:      obj.GetObjectKind().GroupVersionKind().Kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			ShardKey:  key,
+		})
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		if orphans[i].Kind != orphans[j].Kind {
+			return orphans[i].Kind < orphans[j].Kind
+		}
+		if orphans[i].Namespace != orphans[j].Namespace {
+			return orphans[i].Namespace < orphans[j].Namespace
+		}
+		return orphans[i].Name < orphans[j].Name
+	})
+
+	return orphans, nil
+}
+
+// ServedShards returns the shards served by the FluxShardSets.
+func ServedShards(sets []v1alpha1.FluxShardSet) map[string]bool {
+	served := map[string]bool{}
+	for _, set := range sets {
+		for _, shard := range set.Status.Shards {
+			served[shard.Name] = true
+		}
+	}
+
+	return served
+}
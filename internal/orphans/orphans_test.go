@@ -0,0 +1,62 @@
+package orphans
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestFind(t *testing.T) {
+	cl := test.NewFakeFluxClient(
+		test.NewFluxObject("Kustomization", "default", "served", map[string]string{deploys.ShardKeyLabel: "shard-a"}),
+		test.NewFluxObject("Kustomization", "default", "unassigned", nil),
+		test.NewFluxObject("Kustomization", "team-b", "orphan-2", map[string]string{deploys.ShardKeyLabel: "shard-7"}),
+		test.NewFluxObject("Kustomization", "team-a", "orphan-1", map[string]string{deploys.ShardKeyLabel: "shard-7"}),
+		test.NewFluxObject("HelmRelease", "default", "orphan-3", map[string]string{deploys.ShardKeyLabel: "shard-8"}),
+	)
+	served := ServedShards([]templatesv1.FluxShardSet{
+		*test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+			set.Status.Shards = []templatesv1.ShardStatus{{Name: "shard-a"}}
+		}),
+	})
+
+	tests := []struct {
+		name  string
+		kinds []string
+		want  []templatesv1.OrphanedObject
+	}{
+		{
+			name: "all kinds",
+			want: []templatesv1.OrphanedObject{
+				{Kind: "HelmRelease", Namespace: "default", Name: "orphan-3", ShardKey: "shard-8"},
+				{Kind: "Kustomization", Namespace: "team-a", Name: "orphan-1", ShardKey: "shard-7"},
+				{Kind: "Kustomization", Namespace: "team-b", Name: "orphan-2", ShardKey: "shard-7"},
+			},
+		},
+		{
+			name:  "restricted kinds",
+			kinds: []string{"HelmRelease"},
+			want: []templatesv1.OrphanedObject{
+				{Kind: "HelmRelease", Namespace: "default", Name: "orphan-3", ShardKey: "shard-8"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orphans, err := Find(context.TODO(), cl, tt.kinds, served)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, orphans); diff != "" {
+				t.Fatalf("failed to find orphans:\n%s", diff)
+			}
+		})
+	}
+}
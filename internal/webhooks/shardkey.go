@@ -0,0 +1,122 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/assignment"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+)
+
+// ShardKeyMutatorPath is the path the ShardKeyMutator is served on.
+const ShardKeyMutatorPath = "/mutate-flux-shard-key"
+
+//+kubebuilder:webhook:path=/mutate-flux-shard-key,mutating=true,failurePolicy=ignore,sideEffects=None,groups=kustomize.toolkit.fluxcd.io;helm.toolkit.fluxcd.io;source.toolkit.fluxcd.io;notification.toolkit.fluxcd.io;image.toolkit.fluxcd.io,resources=kustomizations;helmreleases;gitrepositories;ocirepositories;helmrepositories;helmcharts;buckets;alerts;providers;receivers;imagerepositories;imagepolicies;imageupdateautomations,verbs=create,versions=*,name=mshardkey.templates.weave.works,admissionReviewVersions=v1
+
+// ShardKeyMutator assigns new Flux objects to shards when they are created, so
+// that they are never reconciled by the unsharded controller.
+//
+// Objects are assigned by the first FluxShardSet, ordered by namespace and
+// name, with an assignment that matches the object.
+type ShardKeyMutator struct {
+	Client client.Client
+}
+
+// SetupWithManager registers the webhook with the Manager's webhook server.
+func (m *ShardKeyMutator) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(ShardKeyMutatorPath, &webhook.Admission{Handler: m})
+
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (m *ShardKeyMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := &unstructured.Unstructured{}
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if _, ok := obj.GetLabels()[deploys.ShardKeyLabel]; ok {
+		return admission.Allowed("object is already assigned to a shard")
+	}
+
+	shard, err := m.pickShard(ctx, req, obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if shard == "" {
+		return admission.Allowed("no FluxShardSet assigns the object to a shard")
+	}
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[deploys.ShardKeyLabel] = shard
+	obj.SetLabels(labels)
+
+	assigned, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, assigned)
+}
+
+func (m *ShardKeyMutator) pickShard(ctx context.Context, req admission.Request, obj *unstructured.Unstructured) (string, error) {
+	// The namespace and name are not always set on the object when it's
+	// created.
+	key := obj.DeepCopy()
+	key.SetNamespace(req.Namespace)
+	if key.GetName() == "" {
+		key.SetName(req.Name)
+	}
+
+	var list templatesv1.FluxShardSetList
+	if err := m.Client.List(ctx, &list); err != nil {
+		return "", fmt.Errorf("failed to list FluxShardSets: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return client.ObjectKeyFromObject(&list.Items[i]).String() < client.ObjectKeyFromObject(&list.Items[j]).String()
+	})
+
+	for i := range list.Items {
+		set := &list.Items[i]
+		if set.Spec.Suspend || set.Spec.Assignment == nil {
+			continue
+		}
+
+		resolved, err := assignment.Resolve(set)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve the assignment of FluxShardSet %s: %w", client.ObjectKeyFromObject(set), err)
+		}
+
+		matches, err := assignment.Matches(resolved, key)
+		if err != nil {
+			return "", fmt.Errorf("failed to match FluxShardSet %s: %w", client.ObjectKeyFromObject(set), err)
+		}
+		if !matches {
+			continue
+		}
+
+		shard, err := assignment.PickShard(ctx, m.Client, resolved, set.Status.Shards, key)
+		if err != nil {
+			return "", fmt.Errorf("failed to pick shard from FluxShardSet %s: %w", client.ObjectKeyFromObject(set), err)
+		}
+		if shard != "" {
+			return shard, nil
+		}
+	}
+
+	return "", nil
+}
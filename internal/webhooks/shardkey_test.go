@@ -0,0 +1,159 @@
+package webhooks
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestShardKeyMutator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, clientgoscheme.AddToScheme(scheme))
+	test.AssertNoError(t, templatesv1.AddToScheme(scheme))
+
+	testEnv := &envtest.Environment{
+		ErrorIfCRDPathMissing: true,
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "config", "crd", "bases"),
+			filepath.Join("..", "controller", "testdata", "crds"),
+		},
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{filepath.Join("..", "..", "config", "webhook", "manifests.yaml")},
+		},
+		Scheme: scheme,
+	}
+
+	cfg, err := testEnv.Start()
+	test.AssertNoError(t, err)
+	defer func() {
+		if err := testEnv.Stop(); err != nil {
+			t.Errorf("failed to stop the test environment: %s", err)
+		}
+	}()
+
+	k8sClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	test.AssertNoError(t, err)
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme,
+		MetricsBindAddress: "0",
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Host:    testEnv.WebhookInstallOptions.LocalServingHost,
+			Port:    testEnv.WebhookInstallOptions.LocalServingPort,
+			CertDir: testEnv.WebhookInstallOptions.LocalServingCertDir,
+		}),
+	})
+	test.AssertNoError(t, err)
+	test.AssertNoError(t, (&ShardKeyMutator{Client: k8sClient}).SetupWithManager(mgr))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("failed to start the manager: %s", err)
+		}
+	}()
+	waitForWebhookServer(t, mgr)
+
+	shardSet := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+		set.Spec.Shards = []templatesv1.ShardSpec{
+			{Name: "shard-a"},
+			{Name: "shard-b"},
+		}
+		set.Spec.Assignment = &templatesv1.Assignment{
+			Strategy: templatesv1.LeastLoadedStrategy,
+			Kinds:    []string{"Kustomization"},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"example.com/shardable": "true"},
+			},
+		}
+	})
+	test.AssertNoError(t, k8sClient.Create(ctx, shardSet))
+	shardSet.Status.Shards = []templatesv1.ShardStatus{
+		{Name: "shard-a", AssignedObjects: 3},
+		{Name: "shard-b", AssignedObjects: 1},
+	}
+	test.AssertNoError(t, k8sClient.Status().Update(ctx, shardSet))
+
+	t.Run("assigning a new object", func(t *testing.T) {
+		ks := newKustomization("assigned", map[string]string{"example.com/shardable": "true"})
+		test.AssertNoError(t, k8sClient.Create(ctx, ks))
+		defer deleteObject(t, k8sClient, ks)
+
+		assertShardKey(t, ks, "shard-b")
+	})
+
+	t.Run("objects already assigned are not changed", func(t *testing.T) {
+		ks := newKustomization("already-assigned", map[string]string{
+			"example.com/shardable": "true",
+			deploys.ShardKeyLabel:   "shard-a",
+		})
+		test.AssertNoError(t, k8sClient.Create(ctx, ks))
+		defer deleteObject(t, k8sClient, ks)
+
+		assertShardKey(t, ks, "shard-a")
+	})
+
+	t.Run("objects not matching the assignment are not assigned", func(t *testing.T) {
+		ks := newKustomization("not-assigned", nil)
+		test.AssertNoError(t, k8sClient.Create(ctx, ks))
+		defer deleteObject(t, k8sClient, ks)
+
+		assertShardKey(t, ks, "")
+	})
+}
+
+func newKustomization(name string, labels map[string]string) *unstructured.Unstructured {
+	ks := test.NewFluxObject("Kustomization", "default", name, labels)
+	ks.SetAPIVersion("kustomize.toolkit.fluxcd.io/v1beta2")
+	ks.Object["spec"] = map[string]any{
+		"interval": "5m",
+		"prune":    true,
+		"sourceRef": map[string]any{
+			"kind": "GitRepository",
+			"name": "flux-system",
+		},
+	}
+
+	return ks
+}
+
+func assertShardKey(t *testing.T, obj client.Object, want string) {
+	t.Helper()
+	if key := obj.GetLabels()[deploys.ShardKeyLabel]; key != want {
+		t.Fatalf("got shard key %q, want %q", key, want)
+	}
+}
+
+func waitForWebhookServer(t *testing.T, mgr ctrl.Manager) {
+	t.Helper()
+	checker := mgr.GetWebhookServer().StartedChecker()
+	for i := 0; i < 50; i++ {
+		if err := checker(nil); err == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("webhook server did not start")
+}
+
+func deleteObject(t *testing.T, cl client.Client, obj client.Object) {
+	t.Helper()
+	if err := cl.Delete(context.TODO(), obj); err != nil {
+		t.Fatal(err)
+	}
+}
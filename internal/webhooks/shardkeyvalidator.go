@@ -0,0 +1,159 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+	"github.com/weaveworks/flux-shard-controller/internal/generators"
+)
+
+// ShardKeyValidatorPath is the path the ShardKeyValidator is served on.
+const ShardKeyValidatorPath = "/validate-flux-shard-key"
+
+// ValidationMode configures what happens when an object has a shard key that
+// is not served.
+type ValidationMode string
+
+const (
+	// EnforceValidation rejects objects with shard keys that are not served.
+	EnforceValidation ValidationMode = "enforce"
+
+	// WarnValidation allows objects with shard keys that are not served, and
+	// returns a warning to the client.
+	WarnValidation ValidationMode = "warn"
+)
+
+// ParseValidationMode parses the mode from a string.
+func ParseValidationMode(s string) (ValidationMode, error) {
+	switch mode := ValidationMode(s); mode {
+	case EnforceValidation, WarnValidation:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("invalid validation mode %q, must be %q or %q", s, EnforceValidation, WarnValidation)
+}
+
+//+kubebuilder:webhook:path=/validate-flux-shard-key,mutating=false,failurePolicy=ignore,sideEffects=None,groups=kustomize.toolkit.fluxcd.io;helm.toolkit.fluxcd.io;source.toolkit.fluxcd.io;notification.toolkit.fluxcd.io;image.toolkit.fluxcd.io,resources=kustomizations;helmreleases;gitrepositories;ocirepositories;helmrepositories;helmcharts;buckets;alerts;providers;receivers;imagerepositories;imagepolicies;imageupdateautomations,verbs=create;update,versions=*,name=vshardkey.templates.weave.works,admissionReviewVersions=v1
+
+// ShardKeyValidator checks that Flux objects labelled with a shard key are
+// labelled for a shard that is served by a FluxShardSet.
+//
+// A shard is served if it's declared or generated by a FluxShardSet or
+// recorded in the status of a FluxShardSet, or if a FluxShardSet discovers
+// shards from the object.
+type ShardKeyValidator struct {
+	Client client.Client
+	Mode   ValidationMode
+}
+
+// SetupWithManager registers the webhook with the Manager's webhook server.
+func (v *ShardKeyValidator) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(ShardKeyValidatorPath, &webhook.Admission{Handler: v})
+
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (v *ShardKeyValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := &unstructured.Unstructured{}
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	key, ok := obj.GetLabels()[deploys.ShardKeyLabel]
+	if !ok {
+		return admission.Allowed("object is not assigned to a shard")
+	}
+
+	// Changes to objects are only validated if the shard key is changed.
+	if req.Operation == admissionv1.Update {
+		old := &unstructured.Unstructured{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if oldKey, ok := old.GetLabels()[deploys.ShardKeyLabel]; ok && oldKey == key {
+			return admission.Allowed("shard key is not changed")
+		}
+	}
+
+	served, err := v.isServed(ctx, obj, key)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if served {
+		return admission.Allowed("shard is served")
+	}
+
+	msg := fmt.Sprintf("no FluxShardSet serves the shard %q in the %s label", key, deploys.ShardKeyLabel)
+	if v.Mode == WarnValidation {
+		return admission.Allowed("").WithWarnings(msg)
+	}
+
+	return admission.Denied(msg)
+}
+
+func (v *ShardKeyValidator) isServed(ctx context.Context, obj client.Object, key string) (bool, error) {
+	var list templatesv1.FluxShardSetList
+	if err := v.Client.List(ctx, &list); err != nil {
+		return false, fmt.Errorf("failed to list FluxShardSets: %w", err)
+	}
+
+	for i := range list.Items {
+		set := &list.Items[i]
+		// The shards declared in the FluxShardSet are used if the generators
+		// fail, they are reported when the FluxShardSet is reconciled.
+		shards, err := generators.ExpandStaticShards(ctx, v.Client, set)
+		if err != nil {
+			shards = set.Spec.Shards
+		}
+		for _, shard := range shards {
+			if shard.Name == key {
+				return true, nil
+			}
+		}
+		for _, shard := range set.Status.Shards {
+			if shard.Name == key {
+				return true, nil
+			}
+		}
+		for _, shard := range set.Status.DiscoveredShards {
+			if shard.Name == key {
+				return true, nil
+			}
+		}
+
+		// Discovery generates shards for valid keys on the objects it
+		// discovers, so the shard will be served when the FluxShardSet is
+		// reconciled.
+		if len(validation.IsDNS1123Label(key)) > 0 {
+			continue
+		}
+		for _, generator := range set.Spec.Generators {
+			if generator.Discovery == nil {
+				continue
+			}
+			matches, err := fluxobjects.Matches(generator.Discovery.Kinds, generator.Discovery.Selector, obj)
+			if err != nil {
+				return false, fmt.Errorf("failed to match FluxShardSet %s: %w", client.ObjectKeyFromObject(set), err)
+			}
+			if matches {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,175 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/deploys"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestShardKeyValidator(t *testing.T) {
+	shardSets := []client.Object{
+		test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+			set.Spec.Shards = []templatesv1.ShardSpec{{Name: "shard-a"}}
+			set.Status.Shards = []templatesv1.ShardStatus{{Name: "shard-a"}, {Name: "shard-0"}}
+		}),
+		test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+			set.Name = "range"
+			set.Spec.Generators = []templatesv1.ShardGenerator{
+				{Range: &templatesv1.RangeGenerator{Start: 1, Count: 2, NameTemplate: "range-{{ .Index }}"}},
+			}
+		}),
+		test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+			set.Name = "discovery"
+			set.Spec.Generators = []templatesv1.ShardGenerator{
+				{Discovery: &templatesv1.DiscoveryGenerator{
+					Kinds: []string{"HelmRelease"},
+				}},
+			}
+			set.Status.DiscoveredShards = []templatesv1.DiscoveredShard{{Name: "team-a"}}
+		}),
+	}
+
+	tests := []struct {
+		name        string
+		mode        ValidationMode
+		kind        string
+		key         string
+		oldKey      string
+		wantAllowed bool
+		wantMessage string
+		wantWarning string
+	}{
+		{
+			name:        "object without shard key",
+			kind:        "Kustomization",
+			wantAllowed: true,
+		},
+		{
+			name:        "shard declared in a FluxShardSet",
+			kind:        "Kustomization",
+			key:         "shard-a",
+			wantAllowed: true,
+		},
+		{
+			name:        "shard in the status of a FluxShardSet",
+			kind:        "Kustomization",
+			key:         "shard-0",
+			wantAllowed: true,
+		},
+		{
+			name:        "shard generated by a FluxShardSet",
+			kind:        "Kustomization",
+			key:         "range-2",
+			wantAllowed: true,
+		},
+		{
+			name:        "shard discovered by a FluxShardSet",
+			kind:        "Kustomization",
+			key:         "team-a",
+			wantAllowed: true,
+		},
+		{
+			name:        "shard discovered from the object",
+			kind:        "HelmRelease",
+			key:         "shard-7",
+			wantAllowed: true,
+		},
+		{
+			name:        "shard not served",
+			kind:        "Kustomization",
+			key:         "shard-7",
+			wantAllowed: false,
+			wantMessage: `no FluxShardSet serves the shard "shard-7" in the sharding.fluxcd.io/key label`,
+		},
+		{
+			name:        "shard not served in warn mode",
+			mode:        WarnValidation,
+			kind:        "Kustomization",
+			key:         "shard-7",
+			wantAllowed: true,
+			wantWarning: `no FluxShardSet serves the shard "shard-7" in the sharding.fluxcd.io/key label`,
+		},
+		{
+			name:        "unchanged shard key",
+			kind:        "Kustomization",
+			key:         "shard-7",
+			oldKey:      "shard-7",
+			wantAllowed: true,
+		},
+		{
+			name:        "changed shard key",
+			kind:        "Kustomization",
+			key:         "shard-7",
+			oldKey:      "shard-a",
+			wantAllowed: false,
+			wantMessage: `no FluxShardSet serves the shard "shard-7" in the sharding.fluxcd.io/key label`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := &ShardKeyValidator{
+				Client: test.NewFakeFluxClient(shardSets...),
+				Mode:   tt.mode,
+			}
+
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    rawFluxObject(t, tt.kind, tt.key),
+			}}
+			if tt.oldKey != "" {
+				req.Operation = admissionv1.Update
+				req.OldObject = rawFluxObject(t, tt.kind, tt.oldKey)
+			}
+
+			resp := validator.Handle(context.TODO(), req)
+
+			if resp.Allowed != tt.wantAllowed {
+				t.Fatalf("got allowed %v, want %v: %s", resp.Allowed, tt.wantAllowed, resp.Result.Message)
+			}
+			if !tt.wantAllowed && resp.Result.Message != tt.wantMessage {
+				t.Errorf("got message %q, want %q", resp.Result.Message, tt.wantMessage)
+			}
+			var wantWarnings []string
+			if tt.wantWarning != "" {
+				wantWarnings = []string{tt.wantWarning}
+			}
+			if diff := cmp.Diff(wantWarnings, resp.Warnings); diff != "" {
+				t.Errorf("failed to match warnings:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseValidationMode(t *testing.T) {
+	if _, err := ParseValidationMode("warn"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ParseValidationMode("unknown")
+	test.AssertErrorMatch(t, `invalid validation mode "unknown", must be "enforce" or "warn"`, err)
+}
+
+func rawFluxObject(t *testing.T, kind, key string) runtime.RawExtension {
+	t.Helper()
+	labels := map[string]string{}
+	if key != "" {
+		labels[deploys.ShardKeyLabel] = key
+	}
+	obj := test.NewFluxObject(kind, "default", "app", labels)
+
+	b, err := json.Marshal(obj)
+	test.AssertNoError(t, err)
+
+	return runtime.RawExtension{Raw: b}
+}
@@ -1,6 +1,8 @@
 package test
 
 import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -10,6 +12,15 @@ import (
 	"k8s.io/utils/pointer"
 )
 
+// IgnoreChecksumAnnotation ignores the checksum annotation that is added to
+// generated Deployments when comparing Deployments.
+var IgnoreChecksumAnnotation = cmp.Options{
+	cmpopts.IgnoreMapEntries(func(k, v string) bool {
+		return k == "templates.weave.works/checksum"
+	}),
+	cmpopts.EquateEmpty(),
+}
+
 // MakeTestDeployment creates a new Deployment and apply the opts to it.
 func MakeTestDeployment(name types.NamespacedName, opts ...func(*appsv1.Deployment)) *appsv1.Deployment {
 	deploy := &appsv1.Deployment{
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/internal/fluxobjects"
+)
+
+// NewFakeFluxClient creates a fake client that can list and patch the Flux
+// kinds as well as the Kubernetes kinds and FluxShardSets, with the provided
+// objects.
+func NewFakeFluxClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+	if err := templatesv1.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+	for _, gk := range fluxobjects.Kinds {
+		gvk := gk.WithVersion("v1")
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	}
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(scheme)).
+		WithObjects(objs...).
+		Build()
+}
+
+// NewFluxObject creates a new Flux object of the named kind e.g.
+// Kustomization.
+func NewFluxObject(kind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(fluxKind(kind).WithVersion("v1"))
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+
+	return obj
+}
+
+func fluxKind(kind string) schema.GroupKind {
+	kinds, err := fluxobjects.ParseKinds([]string{kind})
+	if err != nil {
+		panic(err)
+	}
+
+	return kinds[0]
+}
@@ -5,11 +5,16 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
 )
 
+// IgnoreChecksums ignores the checksums of the resources in the inventory when
+// comparing inventories.
+var IgnoreChecksums = cmpopts.IgnoreFields(templatesv1.ResourceRef{}, "Checksum")
+
 // AssertInventoryHasItems will ensure that each of the provided objects is
 // listed in the Inventory of the provided FluxShardSet.
 func AssertInventoryHasItems(t *testing.T, shardset *templatesv1.FluxShardSet, objs ...runtime.Object) {
@@ -31,7 +36,7 @@ func AssertInventoryHasItems(t *testing.T, shardset *templatesv1.FluxShardSet, o
 		return entries[i].ID < entries[j].ID
 	})
 	want := &templatesv1.ResourceInventory{Entries: entries}
-	if diff := cmp.Diff(want, shardset.Status.Inventory); diff != "" {
+	if diff := cmp.Diff(want, shardset.Status.Inventory, IgnoreChecksums); diff != "" {
 		t.Errorf("failed to get inventory:\n%s", diff)
 	}
 }
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	eventv1 "github.com/fluxcd/pkg/apis/event/v1beta1"
+	"github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
+	"github.com/weaveworks/flux-shard-controller/test"
+)
+
+func TestForwardingEvents(t *testing.T) {
+	ctx := context.TODO()
+	srcDeployment := test.MakeTestDeployment(nsn("default", "kustomize-controller"), func(d *appsv1.Deployment) {
+		d.Spec.Template.Spec.Containers[0].Args = []string{
+			"--watch-label-selector=!sharding.fluxcd.io/key",
+		}
+	})
+	test.AssertNoError(t, testEnv.Create(ctx, srcDeployment))
+	defer func() {
+		test.AssertNoError(t, testEnv.Get(ctx, client.ObjectKeyFromObject(srcDeployment), srcDeployment))
+		deleteObject(t, testEnv, srcDeployment)
+	}()
+
+	shardSet := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+		set.Spec.Shards = []templatesv1.ShardSpec{
+			{
+				Name: "shard-1",
+			},
+		}
+		set.Spec.SourceDeploymentRef = templatesv1.SourceDeploymentReference{
+			Name: srcDeployment.Name,
+		}
+	})
+
+	test.AssertNoError(t, testEnv.Create(ctx, shardSet))
+	defer deleteShardSetAndWaitForNotFound(t, testEnv, shardSet)
+
+	g := gomega.NewWithT(t)
+	g.Eventually(func() []string {
+		messages := []string{}
+		for _, event := range eventServer.Events() {
+			if event.InvolvedObject.Kind == "FluxShardSet" && event.InvolvedObject.Name == shardSet.Name {
+				messages = append(messages, event.Severity+" "+event.Reason+" "+event.Message)
+			}
+		}
+
+		return messages
+	}, timeout).Should(gomega.ContainElement("info DeploymentCreated Deployment default/kustomize-controller-shard-1 created"))
+}
+
+// testEventServer is a stand-in for the Flux notification-controller that
+// records the events it receives.
+type testEventServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	events []eventv1.Event
+}
+
+func newTestEventServer() *testEventServer {
+	s := &testEventServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event eventv1.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		s.events = append(s.events, event)
+		s.mu.Unlock()
+
+		// The notification-controller accepts events with 202 Accepted.
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	return s
+}
+
+// Events returns the events received by the server.
+func (s *testEventServer) Events() []eventv1.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]eventv1.Event{}, s.events...)
+}
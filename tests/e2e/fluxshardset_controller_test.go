@@ -11,6 +11,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -70,7 +71,7 @@ func TestCreatingDeployments(t *testing.T) {
 
 	var created appsv1.Deployment
 	test.AssertNoError(t, testEnv.Get(ctx, client.ObjectKeyFromObject(want), &created))
-	if diff := cmp.Diff(want, &created, ignoreObjectMeta); diff != "" {
+	if diff := cmp.Diff(want, &created, ignoreObjectMeta, test.IgnoreChecksumAnnotation); diff != "" {
 		t.Fatalf("failed to create Deployment:\n%s", diff)
 	}
 }
@@ -133,10 +134,64 @@ func TestUpdatingDeployments(t *testing.T) {
 			return err.Error()
 		}
 
-		return cmp.Diff(shard1Deploy, createdDeploy, ignoreObjectMeta)
+		return cmp.Diff(shard1Deploy, createdDeploy, ignoreObjectMeta, test.IgnoreChecksumAnnotation)
 	}, timeout).Should(gomega.BeEmpty())
 }
 
+func TestGeneratingShardsFromConfigMap(t *testing.T) {
+	ctx := context.TODO()
+	srcDeployment := test.MakeTestDeployment(nsn("default", "kustomize-controller"), func(d *appsv1.Deployment) {
+		d.Spec.Template.Spec.Containers[0].Args = []string{
+			"--watch-label-selector=!sharding.fluxcd.io/key",
+		}
+	})
+	test.AssertNoError(t, testEnv.Create(ctx, srcDeployment))
+	defer func() {
+		test.AssertNoError(t, testEnv.Get(ctx, client.ObjectKeyFromObject(srcDeployment), srcDeployment))
+		deleteObject(t, testEnv, srcDeployment)
+	}()
+
+	shardsConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "shards",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"shards": "shard-a",
+		},
+	}
+	test.AssertNoError(t, testEnv.Create(ctx, shardsConfigMap))
+	defer deleteObject(t, testEnv, shardsConfigMap)
+
+	shardSet := test.NewFluxShardSet(func(set *templatesv1.FluxShardSet) {
+		set.Spec.Generators = []templatesv1.ShardGenerator{
+			{
+				ConfigMap: &templatesv1.ConfigMapGenerator{
+					Name: shardsConfigMap.Name,
+					Key:  "shards",
+				},
+			},
+		}
+		set.Spec.SourceDeploymentRef = templatesv1.SourceDeploymentReference{
+			Name: srcDeployment.Name,
+		}
+	})
+
+	test.AssertNoError(t, testEnv.Create(ctx, shardSet))
+	defer deleteShardSetAndWaitForNotFound(t, testEnv, shardSet)
+
+	waitForFluxShardSetInventory(t, testEnv, shardSet,
+		test.MakeTestDeployment(nsn("default", "kustomize-controller-shard-a")))
+
+	test.AssertNoError(t, testEnv.Get(ctx, client.ObjectKeyFromObject(shardsConfigMap), shardsConfigMap))
+	shardsConfigMap.Data["shards"] = "shard-a\nshard-b"
+	test.AssertNoError(t, testEnv.Update(ctx, shardsConfigMap))
+
+	waitForFluxShardSetInventory(t, testEnv, shardSet,
+		test.MakeTestDeployment(nsn("default", "kustomize-controller-shard-a")),
+		test.MakeTestDeployment(nsn("default", "kustomize-controller-shard-b")))
+}
+
 func waitForFluxShardSetInventory(t *testing.T, k8sClient client.Client, set *templatesv1.FluxShardSet, objs ...runtime.Object) {
 	t.Helper()
 	g := gomega.NewWithT(t)
@@ -157,7 +212,7 @@ func waitForFluxShardSetInventory(t *testing.T, k8sClient client.Client, set *te
 
 		want := generateResourceInventory(t, objs)
 
-		return cmp.Diff(want, updated.Status.Inventory) == ""
+		return cmp.Diff(want, updated.Status.Inventory, test.IgnoreChecksums) == ""
 	}, timeout).Should(gomega.BeTrue())
 }
 
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/fluxcd/pkg/runtime/events"
 	"github.com/fluxcd/pkg/runtime/testenv"
 	templatesv1 "github.com/weaveworks/flux-shard-controller/api/v1alpha1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -22,8 +23,9 @@ const (
 )
 
 var (
-	testEnv *testenv.Environment
-	ctx     = ctrl.SetupSignalHandler()
+	testEnv     *testenv.Environment
+	eventServer *testEventServer
+	ctx         = ctrl.SetupSignalHandler()
 )
 
 func TestMain(m *testing.M) {
@@ -33,9 +35,17 @@ func TestMain(m *testing.M) {
 		filepath.Join("..", "..", "config", "crd", "bases"),
 	))
 
+	eventServer = newTestEventServer()
+
+	eventRecorder, err := events.NewRecorder(testEnv, ctrl.Log, eventServer.URL, "flux-shard-controller")
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create event recorder: %v", err))
+	}
+
 	if err := (&controller.FluxShardSetReconciler{
-		Client: testEnv,
-		Scheme: testEnv.GetScheme(),
+		Client:        testEnv,
+		EventRecorder: eventRecorder,
+		Scheme:        testEnv.GetScheme(),
 	}).SetupWithManager(testEnv); err != nil {
 		panic(fmt.Sprintf("Failed to start FluxShardSetReconciler: %v", err))
 	}
@@ -54,6 +64,7 @@ func TestMain(m *testing.M) {
 	if err := testEnv.Stop(); err != nil {
 		panic(fmt.Sprintf("Failed to stop the test environment: %v", err))
 	}
+	eventServer.Close()
 
 	os.Exit(code)
 }